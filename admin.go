@@ -0,0 +1,95 @@
+package main
+
+import (
+	"net"
+	"sort"
+)
+
+const (
+	PrivilegeAll      = "all"
+	PrivilegeReadOnly = "read-only"
+	AuthServerLocal   = "Local"
+	AuthServerRADIUS  = "radius"
+	AuthServerLDAP    = "ldap"
+	AuthServerSecurID = "securid"
+)
+
+type Admin struct {
+	Name      string
+	Privilege string
+	Root      bool
+}
+
+type AuthServer struct {
+	Name         string
+	Type         string
+	Servers      []string
+	Port         int
+	AccountTypes []string
+	Unsupported  []string
+}
+
+type LocalUser struct {
+	Name     string
+	Types    []string
+	Disabled bool
+}
+
+type AdminConfig struct {
+	Admins      map[string]*Admin
+	ManagerIPs  []*net.IPNet
+	AuthServer  string
+	AuthServers map[string]*AuthServer
+	Users       map[string]*LocalUser
+}
+
+func newAdminConfig() AdminConfig {
+	return AdminConfig{
+		Admins:      make(map[string]*Admin),
+		AuthServers: make(map[string]*AuthServer),
+		Users:       make(map[string]*LocalUser),
+	}
+}
+
+// AuthServerByName returns the auth server with the given name, creating it if it doesn't exist yet.
+func (a AdminConfig) AuthServerByName(name string) *AuthServer {
+	if _, ok := a.AuthServers[name]; !ok {
+		a.AuthServers[name] = &AuthServer{Name: name}
+	}
+	return a.AuthServers[name]
+}
+
+// User returns the local user with the given name, creating it if it doesn't exist yet.
+func (a AdminConfig) User(name string) *LocalUser {
+	if _, ok := a.Users[name]; !ok {
+		a.Users[name] = &LocalUser{Name: name}
+	}
+	return a.Users[name]
+}
+
+func (a AdminConfig) AdminNames() []string {
+	var ret = make([]string, 0, len(a.Admins))
+	for name := range a.Admins {
+		ret = append(ret, name)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+func (a AdminConfig) AuthServerNames() []string {
+	var ret = make([]string, 0, len(a.AuthServers))
+	for name := range a.AuthServers {
+		ret = append(ret, name)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+func (a AdminConfig) UserNames() []string {
+	var ret = make([]string, 0, len(a.Users))
+	for name := range a.Users {
+		ret = append(ret, name)
+	}
+	sort.Strings(ret)
+	return ret
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"math/bits"
+	"net"
+	"sort"
+	"strings"
+)
+
+// maxAggregateCommentNames is the number of object names listed in the comment of an aggregated network.
+const maxAggregateCommentNames = 5
+
+type namedRange struct {
+	Range
+	Name string
+}
+
+// aggregateNetworks collapses a list of networks to the minimal set of CIDRs covering the same addresses. Overlapping
+// and adjacent networks are merged. The names of the original networks covered by each CIDR are returned as the first
+// value. IPv6 networks are only deduplicated.
+func aggregateNetworks(names []string, networks []*net.IPNet) ([][]string, []*net.IPNet) {
+	var retNames [][]string
+	var ret []*net.IPNet
+
+	var ranges = make([]namedRange, 0, len(networks))
+	var ipv6 = make(map[string]int)
+	var ipv6Names = make(map[string]struct{})
+	for idx, n := range networks {
+		if r, ok := ipv4Range(n); ok {
+			ranges = append(ranges, namedRange{r, names[idx]})
+		} else if pos, ok := ipv6[n.String()]; !ok {
+			ipv6[n.String()] = len(ret)
+			ipv6Names[n.String()+" "+names[idx]] = struct{}{}
+			retNames = append(retNames, []string{names[idx]})
+			ret = append(ret, n)
+		} else if _, seen := ipv6Names[n.String()+" "+names[idx]]; !seen {
+			ipv6Names[n.String()+" "+names[idx]] = struct{}{}
+			retNames[pos] = append(retNames[pos], names[idx])
+		}
+	}
+
+	sort.SliceStable(ranges, func(i, j int) bool {
+		if ranges[i].Lo != ranges[j].Lo {
+			return ranges[i].Lo < ranges[j].Lo
+		}
+		return ranges[i].Hi > ranges[j].Hi
+	})
+
+	// Merge overlapping and adjacent ranges, then split each merged range in CIDRs
+	for start := 0; start < len(ranges); {
+		var merged = ranges[start].Range
+		var end = start + 1
+		for end < len(ranges) && uint64(ranges[end].Lo) <= uint64(merged.Hi)+1 {
+			if ranges[end].Hi > merged.Hi {
+				merged.Hi = ranges[end].Hi
+			}
+			end++
+		}
+
+		for _, cidr := range rangeToCIDRs(merged) {
+			var cidrNames []string
+			var seen = make(map[string]struct{})
+			for _, r := range ranges[start:end] {
+				if r.Lo > cidr.Hi {
+					break
+				}
+				if _, ok := seen[r.Name]; !ok && r.Hi >= cidr.Lo {
+					seen[r.Name] = struct{}{}
+					cidrNames = append(cidrNames, r.Name)
+				}
+			}
+			retNames = append(retNames, cidrNames)
+			ret = append(ret, rangeToIPNet(cidr))
+		}
+		start = end
+	}
+	return retNames, ret
+}
+
+// rangeToCIDRs splits an address range in the minimal list of aligned ranges.
+func rangeToCIDRs(r Range) []Range {
+	var ret []Range
+	var lo = uint64(r.Lo)
+	for lo <= uint64(r.Hi) {
+		// Largest block aligned on lo and not past the end of the range
+		size := uint64(1) << bits.TrailingZeros64(lo|1<<32)
+		for lo+size-1 > uint64(r.Hi) {
+			size >>= 1
+		}
+		ret = append(ret, Range{uint32(lo), uint32(lo + size - 1)})
+		lo += size
+	}
+	return ret
+}
+
+func rangeToIPNet(r Range) *net.IPNet {
+	var ones = 32 - bits.Len32(r.Hi-r.Lo)
+	return &net.IPNet{IP: uint32ToIP(r.Lo), Mask: net.CIDRMask(ones, 32)}
+}
+
+// aggregateComment joins the names of the objects covered by an aggregated network.
+func aggregateComment(names []string) string {
+	if len(names) <= maxAggregateCommentNames {
+		return strings.Join(names, ", ")
+	}
+	return fmt.Sprintf("%s and %d more", strings.Join(names[:maxAggregateCommentNames], ", "),
+		len(names)-maxAggregateCommentNames)
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func cidrs(t *testing.T, networks ...string) []*net.IPNet {
+	var ret []*net.IPNet
+	for _, n := range networks {
+		_, network, err := net.ParseCIDR(n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ret = append(ret, network)
+	}
+	return ret
+}
+
+func TestAggregateNetworks(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		networks  []string
+		want      []string
+		wantNames [][]string
+	}{
+		{"adjacent halves", []string{"10.0.0.0/25", "10.0.0.128/25"},
+			[]string{"10.0.0.0/24"}, [][]string{{"10.0.0.0/25", "10.0.0.128/25"}}},
+		{"host inside network", []string{"10.0.0.5/32", "10.0.0.0/24"},
+			[]string{"10.0.0.0/24"}, [][]string{{"10.0.0.0/24", "10.0.0.5/32"}}},
+		{"non aligned", []string{"10.0.0.1/32", "10.0.0.2/31", "10.0.0.4/32"},
+			[]string{"10.0.0.1/32", "10.0.0.2/31", "10.0.0.4/32"}, [][]string{{"10.0.0.1/32"}, {"10.0.0.2/31"}, {"10.0.0.4/32"}}},
+		{"non aligned merged", []string{"10.0.0.3/32", "10.0.0.4/30", "10.0.0.8/32"},
+			[]string{"10.0.0.3/32", "10.0.0.4/30", "10.0.0.8/32"}, [][]string{{"10.0.0.3/32"}, {"10.0.0.4/30"}, {"10.0.0.8/32"}}},
+		{"disjoint", []string{"192.168.1.0/24", "10.0.0.0/24"},
+			[]string{"10.0.0.0/24", "192.168.1.0/24"}, [][]string{{"10.0.0.0/24"}, {"192.168.1.0/24"}}},
+		{"everything", []string{"10.0.0.0/8", "0.0.0.0/0", "255.255.255.255/32"},
+			[]string{"0.0.0.0/0"}, [][]string{{"0.0.0.0/0", "10.0.0.0/8", "255.255.255.255/32"}}},
+		{"last address", []string{"255.255.255.255/32", "255.255.255.254/32"},
+			[]string{"255.255.255.254/31"}, [][]string{{"255.255.255.254/32", "255.255.255.255/32"}}},
+		{"duplicates", []string{"10.0.0.0/24", "10.0.0.0/24"},
+			[]string{"10.0.0.0/24"}, [][]string{{"10.0.0.0/24"}}},
+		{"ipv6", []string{"2001:db8::/64", "10.0.0.0/32", "2001:db8::/64"},
+			[]string{"2001:db8::/64", "10.0.0.0/32"}, [][]string{{"2001:db8::/64"}, {"10.0.0.0/32"}}},
+	} {
+		names, got := aggregateNetworks(tc.networks, cidrs(t, tc.networks...))
+		var gotStrings []string
+		for _, n := range got {
+			gotStrings = append(gotStrings, n.String())
+		}
+		if !reflect.DeepEqual(gotStrings, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.name, gotStrings, tc.want)
+		}
+		if !reflect.DeepEqual(names, tc.wantNames) {
+			t.Errorf("%s: got names %v, want %v", tc.name, names, tc.wantNames)
+		}
+	}
+}
+
+func TestAggregateNetworksManyMembers(t *testing.T) {
+	// Every address of 10.0.0.0/16, in the same object twice
+	var names []string
+	var networks []*net.IPNet
+	for idx := 0; idx < 1<<16; idx++ {
+		for _, name := range []string{fmt.Sprintf("host%d", idx), "all"} {
+			names = append(names, name)
+			networks = append(networks, &net.IPNet{IP: net.IPv4(10, 0, byte(idx>>8), byte(idx)), Mask: net.CIDRMask(32, 32)})
+		}
+	}
+
+	gotNames, got := aggregateNetworks(names, networks)
+	if len(got) != 1 || got[0].String() != "10.0.0.0/16" {
+		t.Fatalf("got %v, want 10.0.0.0/16", got)
+	}
+	if len(gotNames[0]) != 1<<16+1 {
+		t.Errorf("got %d names, want %d", len(gotNames[0]), 1<<16+1)
+	}
+	if comment := aggregateComment(gotNames[0]); !strings.HasSuffix(comment, " and 65532 more") {
+		t.Errorf("got comment %q", comment)
+	}
+}
+
+func TestRangeToCIDRs(t *testing.T) {
+	for _, tc := range []struct {
+		lo, hi string
+		want   []string
+	}{
+		{"10.0.0.0", "10.0.0.255", []string{"10.0.0.0/24"}},
+		{"10.0.0.1", "10.0.0.6", []string{"10.0.0.1/32", "10.0.0.2/31", "10.0.0.4/31", "10.0.0.6/32"}},
+		{"10.0.0.255", "10.0.1.0", []string{"10.0.0.255/32", "10.0.1.0/32"}},
+		{"0.0.0.0", "255.255.255.255", []string{"0.0.0.0/0"}},
+		{"0.0.0.1", "255.255.255.255", nil},
+		{"255.255.255.255", "255.255.255.255", []string{"255.255.255.255/32"}},
+		{"0.0.0.0", "0.0.0.0", []string{"0.0.0.0/32"}},
+	} {
+		var r = Range{ipToUint32(net.ParseIP(tc.lo)), ipToUint32(net.ParseIP(tc.hi))}
+		var got []string
+		for _, cidr := range rangeToCIDRs(r) {
+			got = append(got, rangeToIPNet(cidr).String())
+		}
+		if tc.want == nil {
+			// 0.0.0.1-255.255.255.255 needs one block of each size but the whole space
+			if len(got) != 32 || got[0] != "0.0.0.1/32" || got[31] != "128.0.0.0/1" {
+				t.Errorf("%s-%s: got %v", tc.lo, tc.hi, got)
+			}
+			continue
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s-%s: got %v, want %v", tc.lo, tc.hi, got, tc.want)
+		}
+	}
+}
+
+func TestAggregateComment(t *testing.T) {
+	if got := aggregateComment([]string{"a", "b"}); got != "a, b" {
+		t.Errorf("got %q", got)
+	}
+	if got := aggregateComment([]string{"a", "b", "c", "d", "e", "f", "g"}); got != "a, b, c, d, e and 2 more" {
+		t.Errorf("got %q", got)
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+const (
+	FindingDuplicate = "duplicate"
+	FindingRedundant = "redundant"
+	FindingShadowed  = "shadowed"
+	FindingConflict  = "conflict"
+)
+
+// Finding is an issue between a policy and one or more earlier policies of the same zone pair.
+type Finding struct {
+	Kind    string
+	From    string
+	To      string
+	Policy  int
+	Earlier []int
+	Example Packet
+
+	// Provenance is the one of the policy
+	Provenance Provenance
+}
+
+func (f Finding) String() string {
+	var earlier []string
+	for _, id := range f.Earlier {
+		earlier = append(earlier, fmt.Sprint(id))
+	}
+
+	var what string
+	switch f.Kind {
+	case FindingDuplicate:
+		what = "duplicates"
+	case FindingRedundant:
+		what = "is redundant, already matched with the same action by"
+	case FindingShadowed:
+		what = "is shadowed by"
+	case FindingConflict:
+		what = "conflicts (different action) with earlier"
+	}
+	if len(earlier) > 1 {
+		what += " policies "
+	} else {
+		what += " policy "
+	}
+	return fmt.Sprint(f.From, " -> ", f.To, ": policy ", f.Policy, f.Provenance.suffix(), " ", what,
+		strings.Join(earlier, ", "), ", e.g. ", f.Example)
+}
+
+// analyzePolicies compares the match space of each policy with the earlier policies of the same zone pair, in
+// evaluation order. A policy entirely matched by an earlier one is a duplicate (same match space and action), redundant
+// (same action) or shadowed (different action). A policy entirely matched by several earlier policies is shadowed by
+// all of them. Otherwise, a partial overlap with an earlier policy with a different action is a conflict.
+// Generalizations (an earlier policy entirely matched by a later one with a different action) are not reported.
+// Global policies are also compared, for each zone pair, with the policies of the pair evaluated before them.
+func analyzePolicies(policies []Policy, objects Objects, services Services) []Finding {
+	var findings []Finding
+
+	var pairs = make(map[string][]analyzedPolicy)
+	var pairNames []string
+	var global = make(map[int]bool)
+	for _, p := range policies {
+		if p.Disabled {
+			continue
+		}
+
+		pair := p.From + "__" + p.To
+		if _, ok := pairs[pair]; !ok && p.From != ZoneGlobal && p.To != ZoneGlobal {
+			pairNames = append(pairNames, pair)
+		}
+		if p.From == ZoneGlobal && p.To == ZoneGlobal {
+			global[p.ID] = true
+		}
+
+		space := policySpace(p, objects, services)
+		findings = append(findings, analyzePolicy(p, space, pairs[pair])...)
+		pairs[pair] = append(pairs[pair], analyzedPolicy{policy: p, space: space})
+	}
+
+	// The findings between global policies only are the same for every zone pair, and already reported
+	var globals = pairs[ZoneGlobal+"__"+ZoneGlobal]
+	for _, pair := range pairNames {
+		var earlier = pairs[pair]
+		for idx, g := range globals {
+			for _, f := range analyzePolicy(g.policy, g.space, append(earlier[:len(earlier):len(earlier)], globals[:idx]...)) {
+				for _, id := range f.Earlier {
+					if !global[id] {
+						f.From, f.To = earlier[0].policy.From, earlier[0].policy.To
+						findings = append(findings, f)
+						break
+					}
+				}
+			}
+		}
+	}
+	return findings
+}
+
+// analyzedPolicy is an enabled policy with its match space.
+type analyzedPolicy struct {
+	policy Policy
+	space  Space
+}
+
+// analyzePolicy returns the findings between a policy and the policies evaluated before it.
+func analyzePolicy(p Policy, space Space, earlierPolicies []analyzedPolicy) []Finding {
+	var findings []Finding
+
+	var covered = false
+	var overlapping []int
+	var remaining = space
+	for _, earlier := range earlierPolicies {
+		inter := space.Intersect(earlier.space)
+		if inter.IsEmpty() {
+			continue
+		}
+		overlapping = append(overlapping, earlier.policy.ID)
+		remaining = remaining.Subtract(earlier.space)
+
+		f := Finding{From: p.From, To: p.To, Policy: p.ID, Earlier: []int{earlier.policy.ID}, Example: inter[0].Sample(),
+			Provenance: p.Provenance}
+		sameAction := p.Permits() == earlier.policy.Permits()
+		switch {
+		case space.ContainedIn(earlier.space) && sameAction && earlier.space.ContainedIn(space):
+			f.Kind = FindingDuplicate
+		case space.ContainedIn(earlier.space) && sameAction:
+			f.Kind = FindingRedundant
+		case space.ContainedIn(earlier.space):
+			f.Kind = FindingShadowed
+		case !sameAction && !earlier.space.ContainedIn(space):
+			// A more general policy with a different action (e.g. a final deny) is the usual exception pattern
+			f.Kind = FindingConflict
+		default:
+			continue
+		}
+		findings = append(findings, f)
+
+		if f.Kind != FindingConflict {
+			covered = true
+			break
+		}
+	}
+
+	if !covered && len(overlapping) > 1 && len(space) > 0 && remaining.IsEmpty() {
+		findings = append(findings, Finding{
+			Kind:       FindingShadowed,
+			From:       p.From,
+			To:         p.To,
+			Policy:     p.ID,
+			Earlier:    overlapping,
+			Example:    space[0].Sample(),
+			Provenance: p.Provenance,
+		})
+	}
+	return findings
+}
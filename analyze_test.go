@@ -0,0 +1,37 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAnalyzePolicies(t *testing.T) {
+	const global = `set policy id 5 from "Global" to "Global"  "Any" "Any" "HTTP" permit` + "\n"
+	for _, tc := range []struct {
+		name   string
+		config string
+		want   []Finding
+	}{
+		{"generalization", syncPolicy1 + syncPolicy2, nil},
+		{"shadowed", syncPolicy2 + syncPolicy1,
+			[]Finding{{Kind: FindingShadowed, From: "Trust", To: "DMZ", Policy: 1, Earlier: []int{2}}}},
+		{"conflict", `set policy id 1 from "Trust" to "DMZ"  "h1" "Any" "HTTP" deny` + "\n" +
+			`set policy id 2 from "Trust" to "DMZ"  "Any" "s1" "HTTP" permit` + "\n",
+			[]Finding{{Kind: FindingConflict, From: "Trust", To: "DMZ", Policy: 2, Earlier: []int{1}}}},
+		{"global after the zone pair", syncPolicy2 + global + syncPolicy3,
+			[]Finding{{Kind: FindingDuplicate, From: "Trust", To: "DMZ", Policy: 5, Earlier: []int{2}}}},
+		{"global only", global + strings.Replace(global, "id 5", "id 6", 1),
+			[]Finding{{Kind: FindingDuplicate, From: "Global", To: "Global", Policy: 6, Earlier: []int{5}}}},
+	} {
+		cfg := parse(strings.NewReader(syncAddresses+tc.config), "test.cfg")
+		var got []Finding
+		for _, f := range analyzePolicies(cfg.Policies, cfg.Objects, cfg.Services) {
+			f.Example, f.Provenance = Packet{}, Provenance{}
+			got = append(got, f)
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %+v, want %+v", tc.name, got, tc.want)
+		}
+	}
+}
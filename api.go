@@ -0,0 +1,197 @@
+package main
+
+import (
+	"bufio"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+const (
+	apiPort    = "8728"
+	apiTLSPort = "8729"
+	apiTimeout = 30 * time.Second
+)
+
+// APIReply is the answer of RouterOS to an API command: the !re sentences and the attributes of !done.
+type APIReply struct {
+	Re   []map[string]string
+	Done map[string]string
+}
+
+// APIRunner runs RouterOS API commands, like "/ip/firewall/filter/print".
+type APIRunner interface {
+	Run(words ...string) (APIReply, error)
+}
+
+// APIClient is a client of the RouterOS API protocol.
+type APIClient struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// dialAPI connects to the RouterOS API, on the default port if the address has none.
+func dialAPI(address string, useTLS bool) (*APIClient, error) {
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		if useTLS {
+			address = net.JoinHostPort(address, apiTLSPort)
+		} else {
+			address = net.JoinHostPort(address, apiPort)
+		}
+	}
+
+	var dialer = &net.Dialer{Timeout: apiTimeout}
+	var conn net.Conn
+	var err error
+	if useTLS {
+		// RouterOS uses self-signed certificates by default
+		//nolint:gosec
+		conn, err = tls.DialWithDialer(dialer, "tcp", address, &tls.Config{InsecureSkipVerify: true})
+	} else {
+		conn, err = dialer.Dial("tcp", address)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &APIClient{conn: conn, r: bufio.NewReader(conn)}, nil
+}
+
+func (c *APIClient) Close() error {
+	return c.conn.Close()
+}
+
+// Login authenticates with the post-6.43 plain text login.
+func (c *APIClient) Login(user string, password string) error {
+	_, err := c.Run("/login", "=name="+user, "=password="+password)
+	return err
+}
+
+// Run sends a command and waits for its !done.
+func (c *APIClient) Run(words ...string) (APIReply, error) {
+	var ret = APIReply{Done: make(map[string]string)}
+	_ = c.conn.SetDeadline(time.Now().Add(apiTimeout))
+	if err := writeAPISentence(c.conn, words); err != nil {
+		return ret, err
+	}
+
+	var trap error
+	for {
+		sentence, err := readAPISentence(c.r)
+		if err != nil {
+			return ret, err
+		}
+		if len(sentence) == 0 {
+			continue
+		}
+
+		attrs := apiAttributes(sentence[1:])
+		switch sentence[0] {
+		case "!re":
+			ret.Re = append(ret.Re, attrs)
+		case "!trap":
+			trap = errors.New(strings.Join(words[:1], "") + ": " + attrs["message"])
+		case "!fatal":
+			return ret, errors.New("fatal: " + strings.Join(sentence[1:], " "))
+		case "!done":
+			ret.Done = attrs
+			return ret, trap
+		}
+	}
+}
+
+// apiAttributes converts the "=key=value" words of a sentence to a map.
+func apiAttributes(words []string) map[string]string {
+	var ret = make(map[string]string)
+	for _, w := range words {
+		if strings.HasPrefix(w, "=") {
+			kv := strings.SplitN(w[1:], "=", 2)
+			if len(kv) == 2 {
+				ret[kv[0]] = kv[1]
+			} else {
+				ret[kv[0]] = ""
+			}
+		}
+	}
+	return ret
+}
+
+func writeAPISentence(w io.Writer, words []string) error {
+	var buf []byte
+	for _, word := range words {
+		buf = append(buf, apiLength(len(word))...)
+		buf = append(buf, word...)
+	}
+	buf = append(buf, 0)
+	_, err := w.Write(buf)
+	return err
+}
+
+func readAPISentence(r *bufio.Reader) ([]string, error) {
+	var ret []string
+	for {
+		length, err := readAPILength(r)
+		if err != nil {
+			return nil, err
+		}
+		if length == 0 {
+			return ret, nil
+		}
+		word := make([]byte, length)
+		if _, err := io.ReadFull(r, word); err != nil {
+			return nil, err
+		}
+		ret = append(ret, string(word))
+	}
+}
+
+// apiLength encodes the length of a word.
+func apiLength(l int) []byte {
+	switch {
+	case l < 0x80:
+		return []byte{byte(l)}
+	case l < 0x4000:
+		return []byte{byte(l>>8) | 0x80, byte(l)}
+	case l < 0x200000:
+		return []byte{byte(l>>16) | 0xC0, byte(l >> 8), byte(l)}
+	case l < 0x10000000:
+		return []byte{byte(l>>24) | 0xE0, byte(l >> 16), byte(l >> 8), byte(l)}
+	default:
+		return []byte{0xF0, byte(l >> 24), byte(l >> 16), byte(l >> 8), byte(l)}
+	}
+}
+
+func readAPILength(r *bufio.Reader) (int, error) {
+	first, err := r.ReadByte()
+	if err != nil {
+		return 0, err
+	}
+
+	var extra int
+	var length int
+	switch {
+	case first&0x80 == 0:
+		return int(first), nil
+	case first&0xC0 == 0x80:
+		extra, length = 1, int(first&0x3F)
+	case first&0xE0 == 0xC0:
+		extra, length = 2, int(first&0x1F)
+	case first&0xF0 == 0xE0:
+		extra, length = 3, int(first&0x0F)
+	case first == 0xF0:
+		extra, length = 4, 0
+	default:
+		return 0, fmt.Errorf("invalid API word length 0x%02x", first)
+	}
+	for i := 0; i < extra; i++ {
+		b, err := r.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		length = length<<8 | int(b)
+	}
+	return length, nil
+}
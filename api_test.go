@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestAPILength(t *testing.T) {
+	for _, tc := range []struct {
+		length  int
+		encoded []byte
+	}{
+		{0, []byte{0x00}},
+		{0x7F, []byte{0x7F}},
+		{0x80, []byte{0x80, 0x80}},
+		{0x3FFF, []byte{0xBF, 0xFF}},
+		{0x4000, []byte{0xC0, 0x40, 0x00}},
+		{0x1FFFFF, []byte{0xDF, 0xFF, 0xFF}},
+		{0x200000, []byte{0xE0, 0x20, 0x00, 0x00}},
+		{0xFFFFFFF, []byte{0xEF, 0xFF, 0xFF, 0xFF}},
+		{0x10000000, []byte{0xF0, 0x10, 0x00, 0x00, 0x00}},
+	} {
+		if got := apiLength(tc.length); !bytes.Equal(got, tc.encoded) {
+			t.Errorf("0x%X: encoded as % X, want % X", tc.length, got, tc.encoded)
+		}
+		got, err := readAPILength(bufio.NewReader(bytes.NewReader(tc.encoded)))
+		if err != nil || got != tc.length {
+			t.Errorf("% X: decoded as 0x%X (%v), want 0x%X", tc.encoded, got, err, tc.length)
+		}
+	}
+
+	if _, err := readAPILength(bufio.NewReader(bytes.NewReader([]byte{0xF8}))); err == nil {
+		t.Error("0xF8: invalid length decoded")
+	}
+	if _, err := readAPILength(bufio.NewReader(bytes.NewReader([]byte{0xC0, 0x40}))); err == nil {
+		t.Error("truncated length decoded")
+	}
+}
+
+func TestAPISentence(t *testing.T) {
+	var sentences = [][]string{
+		{"/login", "=name=admin", "=password=secret"},
+		{"/ip/firewall/filter/add", "=comment=" + strings.Repeat("x", 0x80), "=chain=" + strings.Repeat("y", 0x4000)},
+		{"!done"},
+	}
+
+	var buf bytes.Buffer
+	for _, s := range sentences {
+		if err := writeAPISentence(&buf, s); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, want := buf.Bytes()[:8], []byte{6, '/', 'l', 'o', 'g', 'i', 'n', 11}; !bytes.Equal(got, want) {
+		t.Errorf("sentence starts with % X, want % X", got, want)
+	}
+
+	var r = bufio.NewReader(&buf)
+	for _, want := range sentences {
+		got, err := readAPISentence(r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("got %q, want %q", got, want)
+		}
+	}
+	if _, err := readAPISentence(r); err == nil {
+		t.Error("sentence read past the end")
+	}
+}
+
+func TestAPIAttributes(t *testing.T) {
+	got := apiAttributes([]string{"=.id=*1", "=comment=a=b", "=fib", ".tag=3"})
+	want := map[string]string{".id": "*1", "comment": "a=b", "fib": ""}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -7,7 +7,37 @@ import (
 	"strings"
 )
 
-func buildMikrotik(policies []Policy, objects Objects, services Services) string {
+// MikrotikOptions are the optional optimizations of the generated address lists and filter rules, the ownership tag of
+// the generated items, and the provenance of the rules.
+type MikrotikOptions struct {
+	// Aggregate collapses the address lists to the minimal set of CIDRs
+	Aggregate bool
+
+	// Compress merges the addresses and the services of each policy, see buildMikrotikCompressed
+	Compress bool
+
+	// Tag is the comment prefix of the generated items, and TagCleanup removes the previously tagged ones first, see
+	// tagMikrotik
+	Tag        string
+	TagCleanup bool
+
+	// Provenance appends the configuration lines of the policy to the comment of its rules, see provenanceMikrotik
+	Provenance bool
+
+	// Zone is the zone whose policies are converted, empty for all of them. The default deny of the forward chain only
+	// applies to the traffic from or to it, as the policies of the other zone pairs are missing.
+	Zone string
+}
+
+func buildMikrotik(policies []Policy, objects Objects, services Services, opts MikrotikOptions) string {
+	if opts.Provenance {
+		opts.Provenance = false
+		return provenanceMikrotik(buildMikrotik(policies, objects, services, opts), policies)
+	}
+	if opts.Compress {
+		return buildMikrotikCompressed(policies, objects, services, opts)
+	}
+
 	var iplists = make(map[string]int8)
 	var rules strings.Builder
 	rules.WriteString("/ip firewall address-list\n")
@@ -19,9 +49,9 @@ func buildMikrotik(policies []Policy, objects Objects, services Services) string
 
 		// For items with more than one IP, we use address lists
 		for _, src := range p.Sources {
-			names, lookup := objects.Lookup(p.From, src)
+			names, lookup := lookupAddresses(objects, p.From, src, opts)
 			if len(lookup) == 0 {
-				_, _ = fmt.Fprintln(os.Stderr, p.From+" "+src+" not found")
+				_, _ = fmt.Fprintln(os.Stderr, p.From+" "+src+" not found"+p.Provenance.suffix())
 				continue
 			}
 
@@ -42,9 +72,9 @@ func buildMikrotik(policies []Policy, objects Objects, services Services) string
 			}
 		}
 		for _, dst := range p.Destinations {
-			names, lookup := objects.Lookup(p.To, dst)
+			names, lookup := lookupAddresses(objects, p.To, dst, opts)
 			if len(lookup) == 0 {
-				_, _ = fmt.Fprintln(os.Stderr, p.To+" "+dst+" not found")
+				_, _ = fmt.Fprintln(os.Stderr, p.To+" "+dst+" not found"+p.Provenance.suffix())
 				continue
 			}
 
@@ -67,6 +97,7 @@ func buildMikrotik(policies []Policy, objects Objects, services Services) string
 	}
 
 	rules.WriteString("\n\n/ip firewall filter\n")
+	rules.WriteString(mikrotikForwardChain(policies, opts.Zone))
 
 	for _, p := range policies {
 		if p.Disabled || p.IsZonePolicy() {
@@ -87,7 +118,7 @@ func buildMikrotik(policies []Policy, objects Objects, services Services) string
 			var dstAddressLists []string
 
 			for _, srcAddress := range p.Sources {
-				_, lookup := objects.Lookup(p.From, srcAddress)
+				_, lookup := lookupAddresses(objects, p.From, srcAddress, opts)
 				if len(lookup) > 1 {
 					srcAddressLists = append(srcAddressLists, p.From+"__"+srcAddress)
 				} else if len(lookup) == 1 {
@@ -96,7 +127,7 @@ func buildMikrotik(policies []Policy, objects Objects, services Services) string
 				}
 			}
 			for _, dstAddress := range p.Destinations {
-				_, lookup := objects.Lookup(p.To, dstAddress)
+				_, lookup := lookupAddresses(objects, p.To, dstAddress, opts)
 				if len(lookup) > 1 {
 					dstAddressLists = append(dstAddressLists, p.To+"__"+dstAddress)
 				} else if len(lookup) == 1 {
@@ -108,18 +139,18 @@ func buildMikrotik(policies []Policy, objects Objects, services Services) string
 			if serviceName == "ANY" {
 				for idx, srcAddress := range src {
 					for jdx, dstAddress := range dst {
-						rules.WriteString(mikrotikRule(p, p.From+"__"+p.To, srcNames[idx], srcAddress, "", dstNames[jdx], dstAddress, "", "", nil))
+						rules.WriteString(mikrotikRule(p, p.From+"__"+p.To, srcNames[idx], srcAddress, "", dstNames[jdx], dstAddress, "", PortMatch{}))
 					}
 					for _, dstList := range dstAddressLists {
-						rules.WriteString(mikrotikRule(p, p.From+"__"+p.To, srcNames[idx], srcAddress, "", "", nil, dstList, "", nil))
+						rules.WriteString(mikrotikRule(p, p.From+"__"+p.To, srcNames[idx], srcAddress, "", "", nil, dstList, PortMatch{}))
 					}
 				}
 				for _, srcList := range srcAddressLists {
 					for jdx, dstAddress := range dst {
-						rules.WriteString(mikrotikRule(p, p.From+"__"+p.To, "", nil, srcList, dstNames[jdx], dstAddress, "", "", nil))
+						rules.WriteString(mikrotikRule(p, p.From+"__"+p.To, "", nil, srcList, dstNames[jdx], dstAddress, "", PortMatch{}))
 					}
 					for _, dstList := range dstAddressLists {
-						rules.WriteString(mikrotikRule(p, p.From+"__"+p.To, "", nil, srcList, "", nil, dstList, "", nil))
+						rules.WriteString(mikrotikRule(p, p.From+"__"+p.To, "", nil, srcList, "", nil, dstList, PortMatch{}))
 					}
 				}
 				continue
@@ -129,21 +160,21 @@ func buildMikrotik(policies []Policy, objects Objects, services Services) string
 				panic("services not found: " + serviceName)
 			}
 
-			for _, proto := range services[serviceName].AllProtocols() {
+			for _, match := range portMatches(services[serviceName]) {
 				for idx, srcAddress := range src {
 					for jdx, dstAddress := range dst {
-						rules.WriteString(mikrotikRule(p, p.From+"__"+p.To, srcNames[idx], srcAddress, "", dstNames[jdx], dstAddress, "", proto, services[serviceName]))
+						rules.WriteString(mikrotikRule(p, p.From+"__"+p.To, srcNames[idx], srcAddress, "", dstNames[jdx], dstAddress, "", match))
 					}
 					for _, dstList := range dstAddressLists {
-						rules.WriteString(mikrotikRule(p, p.From+"__"+p.To, srcNames[idx], srcAddress, "", "", nil, dstList, proto, services[serviceName]))
+						rules.WriteString(mikrotikRule(p, p.From+"__"+p.To, srcNames[idx], srcAddress, "", "", nil, dstList, match))
 					}
 				}
 				for _, srcList := range srcAddressLists {
 					for jdx, dstAddress := range dst {
-						rules.WriteString(mikrotikRule(p, p.From+"__"+p.To, "", nil, srcList, dstNames[jdx], dstAddress, "", proto, services[serviceName]))
+						rules.WriteString(mikrotikRule(p, p.From+"__"+p.To, "", nil, srcList, dstNames[jdx], dstAddress, "", match))
 					}
 					for _, dstList := range dstAddressLists {
-						rules.WriteString(mikrotikRule(p, p.From+"__"+p.To, "", nil, srcList, "", nil, dstList, proto, services[serviceName]))
+						rules.WriteString(mikrotikRule(p, p.From+"__"+p.To, "", nil, srcList, "", nil, dstList, match))
 					}
 				}
 			}
@@ -154,7 +185,78 @@ func buildMikrotik(policies []Policy, objects Objects, services Services) string
 	return rules.String()
 }
 
-func mikrotikRule(p Policy, chain string, srcName string, src *net.IPNet, srcList string, dstName string, dst *net.IPNet, dstList string, proto string, svc ServiceList) string {
+// lookupAddresses resolves an address book entry like Objects.Lookup, aggregating the addresses when enabled. The
+// first value holds the comment of each address.
+func lookupAddresses(objects Objects, zone string, name string, opts MikrotikOptions) ([]string, []*net.IPNet) {
+	names, lookup := objects.Lookup(zone, name)
+	if !opts.Aggregate || len(lookup) < 2 {
+		return names, lookup
+	}
+
+	aggregatedNames, aggregated := aggregateNetworks(names, lookup)
+	var comments = make([]string, len(aggregatedNames))
+	for idx, n := range aggregatedNames {
+		comments[idx] = aggregateComment(n)
+	}
+	return comments, aggregated
+}
+
+// mikrotikForwardChain dispatches new connections to the zone pair chains, and drops everything else like the ScreenOS
+// default policy does, or only the traffic from and to the zone if not empty. Global policies are evaluated after zone
+// pair ones.
+func mikrotikForwardChain(policies []Policy, zone string) string {
+	var chains = make(map[string]int8)
+	var jumps []string
+	var globalJumps []string
+	for _, p := range policies {
+		if p.Disabled || p.IsZonePolicy() {
+			continue
+		}
+
+		chain := p.From + "__" + p.To
+		if _, ok := chains[chain]; ok {
+			continue
+		}
+		chains[chain] = 1
+
+		var jump strings.Builder
+		jump.WriteString("add chain=forward")
+		if p.From != ZoneGlobal {
+			jump.WriteString(" in-interface-list=")
+			jump.WriteString(p.From)
+		}
+		if p.To != ZoneGlobal {
+			jump.WriteString(" out-interface-list=")
+			jump.WriteString(p.To)
+		}
+		jump.WriteString(" action=jump jump-target=")
+		jump.WriteString(chain)
+		jump.WriteString("\n")
+
+		if p.From == ZoneGlobal || p.To == ZoneGlobal {
+			globalJumps = append(globalJumps, jump.String())
+		} else {
+			jumps = append(jumps, jump.String())
+		}
+	}
+
+	var ret strings.Builder
+	ret.WriteString("add chain=forward connection-state=established,related action=accept\n")
+	ret.WriteString("add chain=forward connection-state=invalid action=drop\n")
+	ret.WriteString(strings.Join(jumps, ""))
+	ret.WriteString(strings.Join(globalJumps, ""))
+	if zone == "" {
+		ret.WriteString("add chain=forward action=drop comment=\"default deny\"\n\n")
+	} else {
+		ret.WriteString("add chain=forward in-interface-list=" + zone + " action=drop comment=\"" + zone + " default deny\"\n")
+		ret.WriteString("add chain=forward out-interface-list=" + zone + " action=drop comment=\"" + zone + " default deny\"\n\n")
+	}
+	return ret.String()
+}
+
+// mikrotikRule returns a rule of the policy. Services with more than one protocol, source port range or more than
+// maxRulePorts destination ports need a rule for each of their portMatches.
+func mikrotikRule(p Policy, chain string, srcName string, src *net.IPNet, srcList string, dstName string, dst *net.IPNet, dstList string, match PortMatch) string {
 	var ret strings.Builder
 	ret.WriteString("add chain=")
 	ret.WriteString(chain)
@@ -175,36 +277,10 @@ func mikrotikRule(p Policy, chain string, srcName string, src *net.IPNet, srcLis
 		ret.WriteString(dstList)
 	}
 
-	if proto != "" {
-		ret.WriteString(" protocol=" + proto)
-	}
-	if proto == "tcp" || proto == "udp" {
-		var srcPorts []string
-		var dstPorts []string
-		for _, s := range svc {
-			if s.Protocol == proto {
-				if s.SrcPortStart != 0 && s.SrcPortEnd != 65535 {
-					srcPorts = append(srcPorts, fmt.Sprintf("%d-%d", s.SrcPortStart, s.SrcPortEnd))
-				}
-				if s.DstPortStart != 0 && s.DstPortEnd != 65535 {
-					dstPorts = append(dstPorts, fmt.Sprintf("%d-%d", s.DstPortStart, s.DstPortEnd))
-				}
-			}
-		}
-		if len(srcPorts) > 0 {
-			ret.WriteString(" src-port=")
-			ret.WriteString(strings.Join(srcPorts, ","))
-		}
-		if len(dstPorts) > 0 {
-			ret.WriteString(" dst-port=")
-			ret.WriteString(strings.Join(dstPorts, ","))
-		}
-	} else if proto == "icmp" {
-		ret.WriteString(" icmp-options=8:0-255")
-	}
+	ret.WriteString(match.String())
 
 	switch {
-	case p.Action == ActionPermit:
+	case p.Action == ActionPermit || p.Action == ActionTunnel:
 		ret.WriteString(" action=accept")
 	case p.Action == ActionReject:
 		ret.WriteString(" action=reject")
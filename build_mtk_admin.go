@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// adminGroups maps ScreenOS admin privileges to RouterOS user groups.
+var adminGroups = map[string]string{
+	PrivilegeAll:      "full",
+	PrivilegeReadOnly: "read",
+}
+
+// radiusServices maps ScreenOS auth-server account types to RouterOS RADIUS services.
+var radiusServices = map[string]string{
+	"admin": "login",
+	"auth":  "hotspot",
+	"xauth": "ipsec",
+	"l2tp":  "ppp",
+}
+
+// buildMikrotikAdmin converts admins, firewall auth users and auth servers. Password hashes and secrets can't be
+// converted, so they are replaced by the "CHANGE-ME" placeholder and reported on stderr; the users are created disabled,
+// so that no account has the placeholder password until it's changed. Manager IPs restrict the RouterOS services and
+// the management input-chain rules (see buildMikrotikInput).
+func buildMikrotikAdmin(admin AdminConfig) string {
+	var groups strings.Builder
+	var users strings.Builder
+	for _, name := range admin.AdminNames() {
+		a := admin.Admins[name]
+		group, ok := adminGroups[a.Privilege]
+		if !ok {
+			_, _ = fmt.Fprintln(os.Stderr, "admin "+name+": privilege "+a.Privilege+" converted to read")
+			group = adminGroups[PrivilegeReadOnly]
+		}
+		_, _ = fmt.Fprintln(os.Stderr, "admin "+name+": password must be set, then the user enabled")
+
+		users.WriteString("add name=")
+		users.WriteString(name)
+		users.WriteString(" group=")
+		users.WriteString(group)
+		users.WriteString(" password=\"CHANGE-ME\" disabled=yes comment=\"")
+		if a.Root {
+			users.WriteString("ScreenOS root admin")
+		} else {
+			users.WriteString("ScreenOS admin")
+		}
+		users.WriteString("\"\n")
+	}
+
+	for _, name := range admin.UserNames() {
+		u := admin.Users[name]
+		var auth = false
+		for _, t := range u.Types {
+			if t == "auth" || t == "xauth" {
+				auth = true
+			} else {
+				_, _ = fmt.Fprintln(os.Stderr, "user "+name+": type "+t+" not converted")
+			}
+		}
+		if !auth {
+			continue
+		}
+		_, _ = fmt.Fprintln(os.Stderr, "user "+name+": password must be set, then the user enabled")
+
+		// Firewall authentication users must not be able to log in to the router
+		if groups.Len() == 0 {
+			groups.WriteString("add name=auth policy=!local,!telnet,!ssh,!ftp,!reboot,!read,!write,!policy,!test," +
+				"!winbox,!password,!web,!sniff,!sensitive,!api,!romon comment=\"ScreenOS auth users\"\n")
+		}
+		users.WriteString("add name=")
+		users.WriteString(name)
+		users.WriteString(" group=auth password=\"CHANGE-ME\" disabled=yes comment=\"ScreenOS auth user")
+		if u.Disabled {
+			users.WriteString(" (disabled in ScreenOS)")
+		}
+		users.WriteString("\"\n")
+	}
+
+	var radius strings.Builder
+	var useRadius = false
+	for _, name := range admin.AuthServerNames() {
+		srv := admin.AuthServers[name]
+		for _, opt := range srv.Unsupported {
+			_, _ = fmt.Fprintln(os.Stderr, "auth-server "+name+": "+opt+" not converted")
+		}
+		if name == AuthServerLocal || len(srv.Servers) == 0 {
+			continue
+		}
+		if srv.Type != AuthServerRADIUS {
+			_, _ = fmt.Fprintln(os.Stderr, "auth-server "+name+": "+srv.Type+" servers are not supported")
+			continue
+		}
+		if name == admin.AuthServer {
+			useRadius = true
+		}
+
+		var services []string
+		for _, t := range srv.AccountTypes {
+			if s, ok := radiusServices[t]; ok {
+				services = appendUnique(services, s)
+			}
+		}
+		if len(services) == 0 {
+			services = []string{radiusServices["admin"]}
+		}
+
+		_, _ = fmt.Fprintln(os.Stderr, "auth-server "+name+": RADIUS secret must be set")
+		for _, address := range srv.Servers {
+			radius.WriteString("add service=")
+			radius.WriteString(strings.Join(services, ","))
+			radius.WriteString(" address=")
+			radius.WriteString(address)
+			radius.WriteString(" secret=\"CHANGE-ME\"")
+			if srv.Port > 0 {
+				radius.WriteString(" authentication-port=")
+				radius.WriteString(fmt.Sprint(srv.Port))
+			}
+			radius.WriteString(" comment=\"")
+			radius.WriteString(name)
+			radius.WriteString("\"\n")
+		}
+	}
+
+	var ret strings.Builder
+	writeSection(&ret, "/user group", groups.String())
+	writeSection(&ret, "/user", users.String())
+	writeSection(&ret, "/radius", radius.String())
+	if useRadius {
+		writeSection(&ret, "/user aaa", "set use-radius=yes\n")
+	}
+
+	if len(admin.ManagerIPs) > 0 {
+		var addresses []string
+		var list strings.Builder
+		for _, ip := range admin.ManagerIPs {
+			addresses = append(addresses, ip.String())
+			list.WriteString("add list=manager-ip address=")
+			list.WriteString(ip.String())
+			list.WriteString("\n")
+		}
+		writeSection(&ret, "/ip service", "set [find] address="+strings.Join(addresses, ",")+"\n")
+		writeSection(&ret, "/ip firewall address-list", list.String())
+	}
+	return ret.String()
+}
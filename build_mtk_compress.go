@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+)
+
+// ruleAddress is the source or destination of a compressed rule: a single address, or an address list.
+type ruleAddress struct {
+	Name    string
+	Address *net.IPNet
+	List    string
+}
+
+// compressedAddresses resolves the sources or destinations of a policy to a single address or address list. A single
+// group keeps its zone address list, while multiple entries are merged in the given per-policy list, whose entries are
+// returned with their comments. The last value is false if no entry can be resolved.
+func compressedAddresses(objects Objects, zone string, names []string, listName string, opts MikrotikOptions) (ruleAddress, []string, []*net.IPNet, bool) {
+	var comments []string
+	var addresses []*net.IPNet
+	var seen = make(map[string]int8)
+	for _, name := range names {
+		n, lookup := lookupAddresses(objects, zone, name, opts)
+		if len(lookup) == 0 {
+			_, _ = fmt.Fprintln(os.Stderr, zone+" "+name+" not found")
+			continue
+		}
+		if strings.ToLower(name) == "any" {
+			return ruleAddress{Name: name, Address: lookup[0]}, nil, nil, true
+		}
+		if len(names) == 1 && len(lookup) > 1 {
+			return ruleAddress{List: zone + "__" + name}, n, lookup, true
+		}
+
+		for idx, address := range lookup {
+			if _, ok := seen[address.String()]; !ok {
+				seen[address.String()] = 1
+				comments = append(comments, n[idx])
+				addresses = append(addresses, address)
+			}
+		}
+	}
+
+	switch {
+	case len(addresses) == 0:
+		return ruleAddress{}, nil, nil, false
+	case len(addresses) == 1:
+		return ruleAddress{Name: strings.Join(names, ","), Address: addresses[0]}, nil, nil, true
+	case opts.Aggregate:
+		var aggregatedNames [][]string
+		aggregatedNames, addresses = aggregateNetworks(comments, addresses)
+		comments = make([]string, len(aggregatedNames))
+		for idx, n := range aggregatedNames {
+			comments[idx] = aggregateComment(n)
+		}
+	}
+	return ruleAddress{List: listName}, comments, addresses, true
+}
+
+// buildMikrotikCompressed converts the policies like buildMikrotik, with one address list for the sources and one for
+// the destinations of each policy, and the ports of all the policy services merged by protocol.
+func buildMikrotikCompressed(policies []Policy, objects Objects, services Services, opts MikrotikOptions) string {
+	var iplists = make(map[string]int8)
+	var lists strings.Builder
+	var rules strings.Builder
+
+	lists.WriteString("/ip firewall address-list\n")
+	rules.WriteString("\n\n/ip firewall filter\n")
+	rules.WriteString(mikrotikForwardChain(policies, opts.Zone))
+
+	for _, p := range policies {
+		if p.Disabled || p.IsZonePolicy() {
+			continue
+		}
+
+		var prefix = fmt.Sprintf("policy%d__", p.ID)
+		src, srcComments, srcAddresses, srcOk := compressedAddresses(objects, p.From, p.Sources, prefix+"src", opts)
+		dst, dstComments, dstAddresses, dstOk := compressedAddresses(objects, p.To, p.Destinations, prefix+"dst", opts)
+		for _, l := range []struct {
+			list      string
+			comments  []string
+			addresses []*net.IPNet
+		}{{src.List, srcComments, srcAddresses}, {dst.List, dstComments, dstAddresses}} {
+			if _, ok := iplists[l.list]; ok || l.list == "" {
+				continue
+			}
+			iplists[l.list] = 1
+			for idx, address := range l.addresses {
+				lists.WriteString("add list=" + l.list + " address=" + address.String() + " comment=\"" +
+					l.comments[idx] + "\"\n")
+			}
+		}
+
+		rules.WriteString("# ")
+		rules.WriteString(p.String())
+		rules.WriteString("\n")
+		if !srcOk || !dstOk {
+			rules.WriteString("\n")
+			continue
+		}
+
+		var entries ServiceList
+		for _, serviceName := range p.Services {
+			if _, ok := services[serviceName]; !ok {
+				panic("services not found: " + serviceName)
+			}
+			entries = append(entries, services[serviceName]...)
+		}
+
+		for _, match := range portMatches(entries) {
+			rules.WriteString(mikrotikRule(p, p.From+"__"+p.To, src.Name, src.Address, src.List, dst.Name,
+				dst.Address, dst.List, match))
+		}
+		rules.WriteString("\n")
+	}
+
+	return lists.String() + rules.String()
+}
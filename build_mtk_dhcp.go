@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+)
+
+// buildMikrotikDHCP converts the interface DHCP servers and relays. Each DHCP server gets its own address pool.
+func buildMikrotikDHCP(interfaces Interfaces, ifmap InterfaceMap) string {
+	var pools strings.Builder
+	var servers strings.Builder
+	var networks strings.Builder
+	var leases strings.Builder
+	var relays strings.Builder
+
+	for _, name := range interfaces.Names() {
+		iface := interfaces[name]
+		mtkName := mikrotikInterfaceName(name, ifmap)
+
+		if dhcp := iface.DHCPServer; dhcp != nil && (len(dhcp.Ranges) > 0 || len(dhcp.Reservations) > 0) {
+			serverName := "dhcp_" + mtkName
+
+			if len(dhcp.Ranges) > 0 {
+				var ranges []string
+				for _, r := range dhcp.Ranges {
+					ranges = append(ranges, r.Start.String()+"-"+r.End.String())
+				}
+				pools.WriteString("add name=")
+				pools.WriteString(serverName)
+				pools.WriteString(" ranges=")
+				pools.WriteString(strings.Join(ranges, ","))
+				pools.WriteString("\n")
+			}
+
+			servers.WriteString("add name=")
+			servers.WriteString(serverName)
+			servers.WriteString(" interface=")
+			servers.WriteString(mtkName)
+			if len(dhcp.Ranges) > 0 {
+				servers.WriteString(" address-pool=")
+				servers.WriteString(serverName)
+			} else {
+				servers.WriteString(" address-pool=static-only")
+			}
+			if dhcp.Lease > 0 {
+				servers.WriteString(" lease-time=")
+				servers.WriteString(mikrotikDuration(dhcp.Lease * 60))
+			}
+			if !dhcp.Enabled {
+				servers.WriteString(" disabled=yes")
+			}
+			servers.WriteString("\n")
+
+			network, gateway := dhcpNetwork(iface)
+			if network == nil {
+				_, _ = fmt.Fprintln(os.Stderr, name+": dhcp server network unknown")
+			} else {
+				networks.WriteString("add address=")
+				networks.WriteString(network.String())
+				if gateway != nil {
+					networks.WriteString(" gateway=")
+					networks.WriteString(gateway.String())
+				}
+				if len(dhcp.DNS) > 0 {
+					var dns []string
+					for _, ip := range dhcp.DNS {
+						dns = append(dns, ip.String())
+					}
+					networks.WriteString(" dns-server=")
+					networks.WriteString(strings.Join(dns, ","))
+				}
+				if dhcp.Domain != "" {
+					networks.WriteString(" domain=")
+					networks.WriteString(dhcp.Domain)
+				}
+				networks.WriteString("\n")
+			}
+
+			for _, r := range dhcp.Reservations {
+				leases.WriteString("add server=")
+				leases.WriteString(serverName)
+				leases.WriteString(" address=")
+				leases.WriteString(r.IP.String())
+				leases.WriteString(" mac-address=")
+				leases.WriteString(mikrotikMAC(r.MAC))
+				leases.WriteString("\n")
+			}
+		}
+
+		if len(iface.DHCPRelayServers) > 0 {
+			relays.WriteString("add name=relay_")
+			relays.WriteString(mtkName)
+			relays.WriteString(" interface=")
+			relays.WriteString(mtkName)
+			relays.WriteString(" dhcp-server=")
+			relays.WriteString(strings.Join(iface.DHCPRelayServers, ","))
+			if len(iface.Addresses) > 0 {
+				relays.WriteString(" local-address=")
+				relays.WriteString(iface.Addresses[0].IP.String())
+			}
+			if !iface.DHCPRelay {
+				relays.WriteString(" disabled=yes")
+			}
+			relays.WriteString("\n")
+		}
+	}
+
+	var ret strings.Builder
+	writeSection(&ret, "/ip pool", pools.String())
+	writeSection(&ret, "/ip dhcp-server", servers.String())
+	writeSection(&ret, "/ip dhcp-server network", networks.String())
+	writeSection(&ret, "/ip dhcp-server lease", leases.String())
+	writeSection(&ret, "/ip dhcp-relay", relays.String())
+	return ret.String()
+}
+
+// dhcpNetwork returns the network served by the interface DHCP server and its default gateway. The interface address
+// is used when the gateway or the netmask options are missing.
+func dhcpNetwork(iface *Interface) (*net.IPNet, net.IP) {
+	var dhcp = iface.DHCPServer
+	var gateway = dhcp.Gateway
+	var mask = dhcp.Netmask
+
+	var probe net.IP
+	if len(dhcp.Ranges) > 0 {
+		probe = dhcp.Ranges[0].Start
+	} else if len(dhcp.Reservations) > 0 {
+		probe = dhcp.Reservations[0].IP
+	}
+	for _, addr := range iface.Addresses {
+		if networkOf(addr).Contains(probe) {
+			if gateway == nil {
+				gateway = addr.IP
+			}
+			if mask == nil {
+				mask = addr.Mask
+			}
+		}
+	}
+
+	if mask == nil {
+		return nil, gateway
+	}
+	return &net.IPNet{IP: probe.Mask(mask), Mask: mask}, gateway
+}
+
+// mikrotikMAC converts a ScreenOS MAC address (e.g. "0011.2233.4455") to the RouterOS format.
+func mikrotikMAC(mac string) string {
+	var hex = strings.ToUpper(strings.NewReplacer(".", "", ":", "").Replace(mac))
+	var ret []string
+	for i := 0; i+1 < len(hex); i += 2 {
+		ret = append(ret, hex[i:i+2])
+	}
+	return strings.Join(ret, ":")
+}
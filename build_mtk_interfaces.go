@@ -0,0 +1,254 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// managePorts maps ScreenOS "manage" options to RouterOS input-chain matchers.
+var managePorts = map[string]string{
+	"ping":   "protocol=icmp",
+	"ssh":    "protocol=tcp dst-port=22",
+	"telnet": "protocol=tcp dst-port=23",
+	"web":    "protocol=tcp dst-port=80",
+	"ssl":    "protocol=tcp dst-port=443",
+	"snmp":   "protocol=udp dst-port=161",
+}
+
+func buildMikrotikInterfaces(interfaces Interfaces, zones []string, ifmap InterfaceMap) string {
+	var ret strings.Builder
+
+	var vlans strings.Builder
+	var members strings.Builder
+	var addresses strings.Builder
+	for _, name := range interfaces.Names() {
+		iface := interfaces[name]
+		mtkName := mikrotikInterfaceName(name, ifmap)
+
+		if iface.Tag > 0 {
+			vlans.WriteString("add name=")
+			vlans.WriteString(mtkName)
+			vlans.WriteString(" interface=")
+			vlans.WriteString(mikrotikInterfaceName(iface.Parent(), ifmap))
+			vlans.WriteString(" vlan-id=")
+			vlans.WriteString(fmt.Sprint(iface.Tag))
+			vlans.WriteString("\n")
+		}
+
+		if iface.Zone != "" {
+			members.WriteString("add list=")
+			members.WriteString(iface.Zone)
+			members.WriteString(" interface=")
+			members.WriteString(mtkName)
+			members.WriteString("\n")
+		}
+
+		for _, addr := range iface.Addresses {
+			addresses.WriteString("add address=")
+			addresses.WriteString(addr.String())
+			addresses.WriteString(" interface=")
+			addresses.WriteString(mtkName)
+			addresses.WriteString("\n")
+		}
+	}
+
+	if vlans.Len() > 0 {
+		ret.WriteString("/interface vlan\n")
+		ret.WriteString(vlans.String())
+		ret.WriteString("\n\n")
+	}
+
+	if len(zones) > 0 {
+		ret.WriteString("/interface list\n")
+		for _, z := range zones {
+			ret.WriteString("add name=")
+			ret.WriteString(z)
+			ret.WriteString("\n")
+		}
+		ret.WriteString("\n\n")
+	}
+	if members.Len() > 0 {
+		ret.WriteString("/interface list member\n")
+		ret.WriteString(members.String())
+		ret.WriteString("\n\n")
+	}
+
+	if addresses.Len() > 0 {
+		ret.WriteString("/ip address\n")
+		ret.WriteString(addresses.String())
+		ret.WriteString("\n\n")
+	}
+
+	return ret.String()
+}
+
+// buildMikrotikNAT converts the NAT mode of the interfaces: their networks are masqueraded when leaving from the
+// egress interfaces. With IPsec, traffic matching an IPsec policy is accepted first, so that it's not masqueraded.
+func buildMikrotikNAT(interfaces Interfaces, routes []Route, ipsec bool) string {
+	var nat strings.Builder
+	if ipsec {
+		nat.WriteString("add chain=srcnat ipsec-policy=out,ipsec action=accept comment=\"IPsec NAT bypass\"\n")
+	}
+	for _, name := range interfaces.Names() {
+		iface := interfaces[name]
+		if iface.Mode != InterfaceModeNAT {
+			continue
+		}
+		egress := natEgressZones(interfaces, routes, iface.Zone)
+		if len(egress) == 0 {
+			_, _ = fmt.Fprintln(os.Stderr, name+": nat mode not converted, no egress interface in another zone")
+		}
+		for _, addr := range iface.Addresses {
+			for _, zone := range egress {
+				nat.WriteString("add chain=srcnat src-address=")
+				nat.WriteString(networkOf(addr).String())
+				nat.WriteString(" out-interface-list=")
+				nat.WriteString(zone)
+				nat.WriteString(" action=masquerade comment=\"")
+				nat.WriteString(name)
+				nat.WriteString(" nat mode\"\n")
+			}
+		}
+	}
+
+	var ret strings.Builder
+	writeSection(&ret, "/ip firewall nat", nat.String())
+	return ret.String()
+}
+
+// natEgressZones returns the zones, other than the given one, of the egress interfaces translated by NAT mode
+// interfaces: the interfaces in route mode, and the ones default routes go through (directly or via a gateway in
+// their network).
+func natEgressZones(interfaces Interfaces, routes []Route, zone string) []string {
+	var egress = make(map[string]bool)
+	for _, name := range interfaces.Names() {
+		if interfaces[name].Mode == InterfaceModeRoute {
+			egress[name] = true
+		}
+	}
+	for _, r := range routes {
+		if ones, _ := r.Prefix.Mask.Size(); ones != 0 || r.IsIPv6() {
+			continue
+		}
+		if r.Interface != "" {
+			egress[r.Interface] = true
+			continue
+		}
+		for _, name := range interfaces.Names() {
+			for _, addr := range interfaces[name].Addresses {
+				if r.Gateway != nil && networkOf(addr).Contains(r.Gateway) {
+					egress[name] = true
+				}
+			}
+		}
+	}
+
+	var ret []string
+	for _, name := range interfaces.Names() {
+		iface := interfaces[name]
+		if egress[name] && iface.Zone != "" && iface.Zone != zone && iface.Mode != InterfaceModeNAT {
+			ret = appendUnique(ret, iface.Zone)
+		}
+	}
+	return ret
+}
+
+// buildMikrotikInput converts the interface "manage" options to input-chain rules, and allows DHCP on interfaces with a
+// DHCP server or relay and IKE/IPsec from the configured gateways. Everything else directed to the router is dropped,
+// as ScreenOS does, unless no login service (ssh, telnet, web or ssl) is managed, as the router would be locked out.
+// When manager IPs are configured, management (except ping) is allowed only from them.
+func buildMikrotikInput(interfaces Interfaces, vpn VPNConfig, admin AdminConfig, ifmap InterfaceMap) string {
+	if len(interfaces) == 0 && len(vpn.Gateways) == 0 {
+		return ""
+	}
+
+	var ret strings.Builder
+	ret.WriteString("/ip firewall filter\n")
+	ret.WriteString("add chain=input connection-state=established,related action=accept\n")
+	ret.WriteString("add chain=input connection-state=invalid action=drop\n")
+
+	var managed = false
+	for _, name := range interfaces.Names() {
+		iface := interfaces[name]
+		for _, m := range iface.Manage {
+			matcher, ok := managePorts[m]
+			if !ok {
+				_, _ = fmt.Fprintln(os.Stderr, name+" manage "+m+" not converted")
+				continue
+			}
+			if m != "ping" && m != "snmp" {
+				managed = true
+			}
+
+			ret.WriteString("add chain=input in-interface=")
+			ret.WriteString(mikrotikInterfaceName(name, ifmap))
+			if len(admin.ManagerIPs) > 0 && m != "ping" {
+				ret.WriteString(" src-address-list=manager-ip")
+			}
+			ret.WriteString(" ")
+			ret.WriteString(matcher)
+			ret.WriteString(" action=accept comment=\"")
+			ret.WriteString(name)
+			ret.WriteString(" manage ")
+			ret.WriteString(m)
+			ret.WriteString("\"\n")
+		}
+	}
+
+	for _, name := range interfaces.Names() {
+		iface := interfaces[name]
+		if (iface.DHCPServer == nil || !iface.DHCPServer.Enabled) && !iface.DHCPRelay {
+			continue
+		}
+
+		ret.WriteString("add chain=input in-interface=")
+		ret.WriteString(mikrotikInterfaceName(name, ifmap))
+		ret.WriteString(" protocol=udp dst-port=67 action=accept comment=\"")
+		ret.WriteString(name)
+		ret.WriteString(" dhcp\"\n")
+	}
+
+	for _, name := range vpn.GatewayNames() {
+		gw := vpn.Gateways[name]
+		if gw.Mode == "" {
+			continue
+		}
+
+		var matcher strings.Builder
+		if gw.OutgoingInterface != "" {
+			matcher.WriteString(" in-interface=")
+			matcher.WriteString(mikrotikInterfaceName(gw.OutgoingInterface, ifmap))
+		}
+		if !gw.Dynamic {
+			matcher.WriteString(" src-address=")
+			matcher.WriteString(gw.Address)
+		}
+		for _, proto := range []string{"protocol=udp dst-port=500,4500", "protocol=ipsec-esp"} {
+			ret.WriteString("add chain=input")
+			ret.WriteString(matcher.String())
+			ret.WriteString(" ")
+			ret.WriteString(proto)
+			ret.WriteString(" action=accept comment=\"ike gateway ")
+			ret.WriteString(name)
+			ret.WriteString("\"\n")
+		}
+	}
+
+	// Without any management access the drop would lock the router out
+	if managed {
+		ret.WriteString("add chain=input action=drop comment=\"management not enabled\"\n")
+	} else {
+		_, _ = fmt.Fprintln(os.Stderr, "no interface manages ssh, telnet, web or ssl: the input chain drop is left out")
+	}
+	ret.WriteString("\n\n")
+	return ret.String()
+}
+
+func mikrotikInterfaceName(name string, ifmap InterfaceMap) string {
+	mtkName, ok := ifmap.Name(name)
+	if !ok {
+		_, _ = fmt.Fprintln(os.Stderr, "interface "+name+" not mapped")
+	}
+	return mtkName
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// maxRulePorts is the maximum number of ports and port ranges RouterOS accepts in a src-port or dst-port list.
+const maxRulePorts = 15
+
+// PortMatch is the protocol part of a filter rule. An empty protocol matches every packet.
+type PortMatch struct {
+	Protocol string
+	SrcPorts []string
+	DstPorts []string
+
+	// ICMPType is the ICMP type for icmp-options, 0 for any type like in Service
+	ICMPType int
+}
+
+func (m PortMatch) String() string {
+	var ret strings.Builder
+	if m.Protocol != "" {
+		ret.WriteString(" protocol=" + m.Protocol)
+	}
+	if len(m.SrcPorts) > 0 {
+		ret.WriteString(" src-port=" + strings.Join(m.SrcPorts, ","))
+	}
+	if len(m.DstPorts) > 0 {
+		ret.WriteString(" dst-port=" + strings.Join(m.DstPorts, ","))
+	}
+	if m.Protocol == "icmp" && m.ICMPType != 0 {
+		ret.WriteString(fmt.Sprintf(" icmp-options=%d:0-255", m.ICMPType))
+	}
+	return ret.String()
+}
+
+// portMatches returns the protocol matchers for the given service entries: one for each protocol and source port
+// range, with all the destination ports merged and split in lists of at most maxRulePorts entries. ICMP entries get
+// one matcher for each type. An entry without protocol matches everything.
+func portMatches(entries ServiceList) []PortMatch {
+	var byProtocol = make(map[string]ServiceList)
+	for _, s := range entries {
+		if s.Protocol == "" {
+			return []PortMatch{{}}
+		}
+		byProtocol[s.Protocol] = append(byProtocol[s.Protocol], s)
+	}
+
+	var protocols = make([]string, 0, len(byProtocol))
+	for proto := range byProtocol {
+		protocols = append(protocols, proto)
+	}
+	sort.Strings(protocols)
+
+	var ret []PortMatch
+	for _, proto := range protocols {
+		switch proto {
+		case "tcp", "udp":
+			ret = append(ret, tcpUDPPortMatches(proto, byProtocol[proto])...)
+		case "icmp":
+			var types []int
+			for _, s := range byProtocol[proto] {
+				if s.IcmpType == 0 {
+					// Any ICMP type
+					types = []int{0}
+					break
+				}
+				types = append(types, s.IcmpType)
+			}
+			sort.Ints(types)
+			for idx, t := range types {
+				if idx == 0 || types[idx-1] != t {
+					ret = append(ret, PortMatch{Protocol: proto, ICMPType: t})
+				}
+			}
+		default:
+			ret = append(ret, PortMatch{Protocol: proto})
+		}
+	}
+	return ret
+}
+
+func tcpUDPPortMatches(proto string, entries ServiceList) []PortMatch {
+	var bySrc = make(map[Range][]Range)
+	for _, s := range entries {
+		src := Range{uint32(s.SrcPortStart), uint32(s.SrcPortEnd)}
+		bySrc[src] = append(bySrc[src], Range{uint32(s.DstPortStart), uint32(s.DstPortEnd)})
+	}
+
+	var srcRanges = make([]Range, 0, len(bySrc))
+	for src := range bySrc {
+		srcRanges = append(srcRanges, src)
+	}
+	sort.Slice(srcRanges, func(i, j int) bool {
+		if srcRanges[i].Lo != srcRanges[j].Lo {
+			return srcRanges[i].Lo < srcRanges[j].Lo
+		}
+		return srcRanges[i].Hi < srcRanges[j].Hi
+	})
+
+	var ret []PortMatch
+	for _, src := range srcRanges {
+		var srcPorts []string
+		if src != fullPortRange {
+			srcPorts = []string{portRangeString(src)}
+		}
+
+		var dstPorts []string
+		for _, dst := range mergeRanges(bySrc[src]) {
+			if dst == fullPortRange {
+				dstPorts = nil
+				break
+			}
+			dstPorts = append(dstPorts, portRangeString(dst))
+		}
+
+		if len(dstPorts) == 0 {
+			ret = append(ret, PortMatch{Protocol: proto, SrcPorts: srcPorts})
+		}
+		for start := 0; start < len(dstPorts); start += maxRulePorts {
+			end := start + maxRulePorts
+			if end > len(dstPorts) {
+				end = len(dstPorts)
+			}
+			ret = append(ret, PortMatch{Protocol: proto, SrcPorts: srcPorts, DstPorts: dstPorts[start:end]})
+		}
+	}
+	return ret
+}
+
+// mergeRanges sorts the ranges, merging overlapping and adjacent ones.
+func mergeRanges(ranges []Range) []Range {
+	var sorted = append([]Range(nil), ranges...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Lo < sorted[j].Lo })
+
+	var ret []Range
+	for _, r := range sorted {
+		if len(ret) > 0 && uint64(r.Lo) <= uint64(ret[len(ret)-1].Hi)+1 {
+			if r.Hi > ret[len(ret)-1].Hi {
+				ret[len(ret)-1].Hi = r.Hi
+			}
+			continue
+		}
+		ret = append(ret, r)
+	}
+	return ret
+}
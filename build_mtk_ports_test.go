@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func tcpEntry(srcStart, srcEnd, dstStart, dstEnd int) Service {
+	return Service{Protocol: "tcp", SrcPortStart: srcStart, SrcPortEnd: srcEnd, DstPortStart: dstStart, DstPortEnd: dstEnd}
+}
+
+func TestPortMatchesMSNetlogon(t *testing.T) {
+	got := portMatches(defaultServices()["MS-NETLOGON"])
+	want := []PortMatch{
+		{Protocol: "tcp", DstPorts: []string{"139", "445", "1024-5000", "49152-65535"}},
+		{Protocol: "udp", DstPorts: []string{"137-138"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestPortMatchesSourcePortRanges(t *testing.T) {
+	got := portMatches(ServiceList{
+		tcpEntry(1024, 65535, 80, 80),
+		tcpEntry(0, 65535, 443, 443),
+		tcpEntry(53, 53, 53, 53),
+		tcpEntry(1024, 65535, 81, 81),
+	})
+	want := []PortMatch{
+		{Protocol: "tcp", DstPorts: []string{"443"}},
+		{Protocol: "tcp", SrcPorts: []string{"53"}, DstPorts: []string{"53"}},
+		{Protocol: "tcp", SrcPorts: []string{"1024-65535"}, DstPorts: []string{"80-81"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestPortMatchesChunks(t *testing.T) {
+	for _, tc := range []struct {
+		ports  int
+		chunks []int
+	}{
+		{1, []int{1}},
+		{maxRulePorts, []int{maxRulePorts}},
+		{maxRulePorts + 1, []int{maxRulePorts, 1}},
+		{2*maxRulePorts + 5, []int{maxRulePorts, maxRulePorts, 5}},
+	} {
+		// Ports two apart, so that they are not merged in ranges
+		var entries ServiceList
+		for idx := 0; idx < tc.ports; idx++ {
+			entries = append(entries, tcpEntry(0, 65535, 1000+2*idx, 1000+2*idx))
+		}
+
+		got := portMatches(entries)
+		if len(got) != len(tc.chunks) {
+			t.Fatalf("%d ports: got %d matches, want %d", tc.ports, len(got), len(tc.chunks))
+		}
+		var next = 1000
+		for idx, m := range got {
+			if len(m.DstPorts) != tc.chunks[idx] {
+				t.Errorf("%d ports: match %d has %d ports, want %d", tc.ports, idx, len(m.DstPorts), tc.chunks[idx])
+			}
+			for _, port := range m.DstPorts {
+				if port != fmt.Sprint(next) {
+					t.Errorf("%d ports: got port %s, want %d", tc.ports, port, next)
+				}
+				next += 2
+			}
+		}
+	}
+}
+
+func TestPortMatchesFullRanges(t *testing.T) {
+	got := portMatches(ServiceList{tcpEntry(0, 65535, 80, 80), tcpEntry(0, 65535, 0, 65535)})
+	want := []PortMatch{{Protocol: "tcp"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+
+	got = portMatches(ServiceList{{}, tcpEntry(0, 65535, 80, 80)})
+	want = []PortMatch{{}}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("any protocol: got %+v, want %+v", got, want)
+	}
+}
+
+func TestPortMatchesICMP(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		entries ServiceList
+		want    []string
+	}{
+		{"ping", defaultServices()["PING"], []string{" protocol=icmp icmp-options=8:0-255"}},
+		{"types", ServiceList{{Protocol: "icmp", IcmpType: 8}, {Protocol: "icmp", IcmpType: 3}, {Protocol: "icmp", IcmpType: 8}},
+			[]string{" protocol=icmp icmp-options=3:0-255", " protocol=icmp icmp-options=8:0-255"}},
+		{"any type", ServiceList{{Protocol: "icmp", IcmpType: 8}, {Protocol: "icmp"}}, []string{" protocol=icmp"}},
+		{"mixed", ServiceList{{Protocol: "icmp", IcmpType: 0}, tcpEntry(0, 65535, 22, 22)},
+			[]string{" protocol=icmp", " protocol=tcp dst-port=22"}},
+	} {
+		var got []string
+		for _, m := range portMatches(tc.entries) {
+			got = append(got, m.String())
+		}
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %q, want %q", tc.name, got, tc.want)
+		}
+	}
+}
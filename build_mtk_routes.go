@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+func buildMikrotikRoutes(vrouters []string, routes []Route, ifmap InterfaceMap) string {
+	var ret strings.Builder
+
+	// Non-default virtual routers become FIB routing tables
+	var tables = 0
+	for _, vr := range vrouters {
+		if vr == DefaultVRouter {
+			continue
+		}
+		if tables == 0 {
+			ret.WriteString("/routing table\n")
+		}
+		ret.WriteString("add name=")
+		ret.WriteString(vr)
+		ret.WriteString(" fib\n")
+		tables++
+	}
+	if tables > 0 {
+		ret.WriteString("\n\n")
+	}
+
+	for _, ipv6 := range []bool{false, true} {
+		var section strings.Builder
+		for _, r := range routes {
+			if r.IsIPv6() != ipv6 {
+				continue
+			}
+			section.WriteString(mikrotikRoute(r, ifmap))
+		}
+		if section.Len() == 0 {
+			continue
+		}
+
+		if ipv6 {
+			ret.WriteString("/ipv6 route\n")
+		} else {
+			ret.WriteString("/ip route\n")
+		}
+		ret.WriteString(section.String())
+		ret.WriteString("\n\n")
+	}
+
+	return ret.String()
+}
+
+func mikrotikRoute(r Route, ifmap InterfaceMap) string {
+	var gateway string
+	var iface string
+	if r.Interface != "" {
+		iface = mikrotikInterfaceName(r.Interface, ifmap)
+	}
+
+	switch {
+	case r.Gateway != nil && !r.Gateway.IsUnspecified() && iface != "":
+		gateway = r.Gateway.String() + "%" + iface
+	case r.Gateway != nil && !r.Gateway.IsUnspecified():
+		gateway = r.Gateway.String()
+	case iface != "":
+		gateway = iface
+	default:
+		// Routes pointing to another virtual router (route leaking) have no direct equivalent
+		_, _ = fmt.Fprintln(os.Stderr, "route "+r.Prefix.String()+" in "+r.VRouter+" to vrouter "+r.NextVRouter+" not converted")
+		return "# route " + r.Prefix.String() + " to vrouter " + r.NextVRouter + " not converted\n"
+	}
+
+	if r.Metric > 1 {
+		_, _ = fmt.Fprintln(os.Stderr, "route "+r.Prefix.String()+" in "+r.VRouter+": metric "+fmt.Sprint(r.Metric)+" ignored")
+	}
+
+	var ret strings.Builder
+	ret.WriteString("add dst-address=")
+	ret.WriteString(r.Prefix.String())
+	ret.WriteString(" gateway=")
+	ret.WriteString(gateway)
+	if r.Preference > 0 {
+		ret.WriteString(" distance=")
+		ret.WriteString(fmt.Sprint(r.Preference))
+	}
+	if r.VRouter != DefaultVRouter {
+		ret.WriteString(" routing-table=")
+		ret.WriteString(r.VRouter)
+	}
+	if r.Description != "" {
+		ret.WriteString(" comment=\"")
+		ret.WriteString(strings.ReplaceAll(r.Description, "\"", ""))
+		ret.WriteString("\"")
+	}
+	ret.WriteString("\n")
+	return ret.String()
+}
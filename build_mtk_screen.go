@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ScreenOS defaults for screen thresholds which are enabled without an explicit value.
+const (
+	defaultSynFloodThreshold  = 4000
+	defaultICMPFloodThreshold = 1000
+	defaultUDPFloodThreshold  = 1000
+	defaultPortScanThreshold  = 5000
+)
+
+// buildMikrotikScreen converts the zone attack protections. Stateless protections become raw drops, rate-based ones
+// are placed in a "Zone__screen" chain which packets from the zone interface list jump to, from both the forward and
+// the input chains. Each rate check has its own sub-chain, which returns while the rate is under the
+// threshold and drops the exceeding packets.
+func buildMikrotikScreen(screens Screens, interfaces Interfaces) string {
+	var raw strings.Builder
+	var lists strings.Builder
+	var filter strings.Builder
+	var synCookies = false
+
+	for _, zone := range screens.Zones() {
+		s := screens[zone]
+		for _, opt := range s.Unsupported {
+			_, _ = fmt.Fprintln(os.Stderr, "zone "+zone+": screen "+opt+" not converted")
+		}
+
+		comment := " comment=\"" + zone + " screen "
+		if s.Land {
+			raw.WriteString("add chain=prerouting in-interface-list=" + zone + " src-address-type=local action=drop" +
+				comment + "land\"\n")
+		}
+		if s.TearDrop {
+			// Fragments smaller than the minimum IPv4 MTU are never legitimate
+			raw.WriteString("add chain=prerouting in-interface-list=" + zone + " fragment=yes packet-size=0-67 action=drop" +
+				comment + "tear-drop\"\n")
+		}
+		if s.PingDeath {
+			raw.WriteString("add chain=prerouting in-interface-list=" + zone + " protocol=icmp fragment=yes action=drop" +
+				comment + "ping-death\"\n")
+		}
+		if s.IPSpoofing {
+			// Addresses connected to the interfaces of other zones can't come from this zone
+			var spoofed = 0
+			for _, name := range interfaces.Names() {
+				iface := interfaces[name]
+				if iface.Zone == zone || iface.Zone == "" {
+					continue
+				}
+				for _, addr := range iface.Addresses {
+					lists.WriteString("add list=" + zone + "__spoofed address=" + networkOf(addr).String() +
+						" comment=\"" + name + "\"\n")
+					spoofed++
+				}
+			}
+			if spoofed == 0 {
+				_, _ = fmt.Fprintln(os.Stderr, "zone "+zone+": screen ip-spoofing not converted, no networks in other zones")
+			} else {
+				raw.WriteString("add chain=prerouting in-interface-list=" + zone + " src-address-list=" + zone +
+					"__spoofed action=drop" + comment + "ip-spoofing\"\n")
+			}
+		}
+
+		var chain = zone + "__screen"
+		var rules strings.Builder
+		if s.SynFlood {
+			synCookies = true
+			if s.SynFloodAttackThreshold > 0 {
+				_, _ = fmt.Fprintln(os.Stderr, "zone "+zone+": screen syn-flood attack-threshold replaced by SYN cookies")
+			}
+			rules.WriteString(mikrotikScreenLimit(chain, "syn-flood-source", "protocol=tcp tcp-flags=syn,!ack",
+				orDefault(s.SynFloodSourceThreshold, defaultSynFloodThreshold), "src-address"))
+			rules.WriteString(mikrotikScreenLimit(chain, "syn-flood-destination", "protocol=tcp tcp-flags=syn,!ack",
+				orDefault(s.SynFloodDestinationThreshold, defaultSynFloodThreshold), "dst-address"))
+		}
+		if s.ICMPFlood {
+			rules.WriteString(mikrotikScreenLimit(chain, "icmp-flood", "protocol=icmp",
+				orDefault(s.ICMPFloodThreshold, defaultICMPFloodThreshold), "dst-address"))
+		}
+		if s.UDPFlood {
+			rules.WriteString(mikrotikScreenLimit(chain, "udp-flood", "protocol=udp",
+				orDefault(s.UDPFloodThreshold, defaultUDPFloodThreshold), "dst-address"))
+		}
+		if s.PortScan {
+			// More than 10 ports probed, each within the threshold from the previous one
+			delay := orDefault(s.PortScanThreshold, defaultPortScanThreshold) / 1000
+			if delay == 0 {
+				delay = 1
+			}
+			rules.WriteString("add chain=" + chain + " protocol=tcp psd=10," + fmt.Sprint(delay) + "ms,1,1 action=drop" +
+				comment + "port-scan\"\n")
+		}
+		if s.SessionLimitSource > 0 {
+			rules.WriteString("add chain=" + chain + " connection-state=new connection-limit=" + fmt.Sprint(s.SessionLimitSource) +
+				",32 action=drop" + comment + "limit-session source-ip-based\"\n")
+		}
+		if s.SessionLimitDestination > 0 {
+			_, _ = fmt.Fprintln(os.Stderr, "zone "+zone+": screen limit-session destination-ip-based not converted")
+		}
+
+		if rules.Len() > 0 {
+			for _, parent := range []string{"forward", "input"} {
+				filter.WriteString("add chain=" + parent + " in-interface-list=" + zone +
+					" action=jump jump-target=" + chain + "\n")
+			}
+			filter.WriteString(rules.String())
+		}
+	}
+
+	var ret strings.Builder
+	if synCookies {
+		writeSection(&ret, "/ip settings", "set tcp-syncookies=yes\n")
+	}
+	writeSection(&ret, "/ip firewall address-list", lists.String())
+	writeSection(&ret, "/ip firewall raw", raw.String())
+	writeSection(&ret, "/ip firewall filter", filter.String())
+	return ret.String()
+}
+
+// mikrotikScreenLimit jumps packets matching the given matcher to a sub-chain, which returns while their rate per
+// src-address or dst-address is under the threshold, and drops them otherwise.
+func mikrotikScreenLimit(chain string, check string, matcher string, threshold int, mode string) string {
+	var ret strings.Builder
+	var subChain = chain + "-" + check
+	var limit = fmt.Sprint(threshold)
+	ret.WriteString("add chain=" + chain + " " + matcher + " action=jump jump-target=" + subChain + "\n")
+	ret.WriteString("add chain=" + subChain + " dst-limit=" + limit + "," + limit + "," + mode + "/1m action=return\n")
+	ret.WriteString("add chain=" + subChain + " action=drop comment=\"" + strings.TrimSuffix(chain, "__screen") +
+		" screen " + check + " threshold " + limit + "\"\n")
+	return ret.String()
+}
+
+func orDefault(value int, defaultValue int) int {
+	if value == 0 {
+		return defaultValue
+	}
+	return value
+}
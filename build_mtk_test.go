@@ -0,0 +1,50 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestForwardChainDefaultDeny(t *testing.T) {
+	cfg := parse(strings.NewReader(syncAddresses+syncPolicy1), "test.cfg")
+	for _, tc := range []struct {
+		zone string
+		want []string
+	}{
+		{"", []string{`add chain=forward action=drop comment="default deny"`}},
+		{"Trust", []string{
+			`add chain=forward in-interface-list=Trust action=drop comment="Trust default deny"`,
+			`add chain=forward out-interface-list=Trust action=drop comment="Trust default deny"`,
+		}},
+	} {
+		var drops []string
+		for _, line := range strings.Split(mikrotikForwardChain(cfg.Policies, tc.zone), "\n") {
+			if strings.HasSuffix(line, `default deny"`) {
+				drops = append(drops, line)
+			}
+		}
+		if strings.Join(drops, "\n") != strings.Join(tc.want, "\n") {
+			t.Errorf("zone %q: got %q, want %q", tc.zone, drops, tc.want)
+		}
+	}
+}
+
+func TestInputChainDrop(t *testing.T) {
+	const drop = `add chain=input action=drop comment="management not enabled"`
+	for _, tc := range []struct {
+		manage []string
+		drop   bool
+	}{
+		{nil, false},
+		{[]string{"ping", "snmp"}, false},
+		{[]string{"ping", "ssh"}, true},
+		{[]string{"web"}, true},
+	} {
+		cfg := parse(strings.NewReader("set interface ethernet0/1 zone Trust\nset interface ethernet0/1 ip 10.0.0.1/24\n"), "test.cfg")
+		cfg.Interfaces["ethernet0/1"].Manage = tc.manage
+		got := buildMikrotikInput(cfg.Interfaces, cfg.VPN, cfg.Admin, make(InterfaceMap))
+		if strings.Contains(got, drop) != tc.drop {
+			t.Errorf("manage %v: got\n%s", tc.manage, got)
+		}
+	}
+}
@@ -0,0 +1,339 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+)
+
+var ipsecDHGroups = map[int]string{
+	1:  "modp768",
+	2:  "modp1024",
+	5:  "modp1536",
+	14: "modp2048",
+	15: "modp3072",
+	16: "modp4096",
+	19: "ecp256",
+	20: "ecp384",
+	21: "ecp521",
+}
+
+var ipsecP1Encryption = map[string]string{
+	"des":    "des",
+	"3des":   "3des",
+	"aes128": "aes-128",
+	"aes192": "aes-192",
+	"aes256": "aes-256",
+}
+
+var ipsecP1Hash = map[string]string{
+	"md5":      "md5",
+	"sha-1":    "sha1",
+	"sha2-256": "sha256",
+	"sha2-384": "sha384",
+	"sha2-512": "sha512",
+}
+
+var ipsecP2Encryption = map[string]string{
+	"":       "null",
+	"null":   "null",
+	"des":    "des",
+	"3des":   "3des",
+	"aes128": "aes-128-cbc",
+	"aes192": "aes-192-cbc",
+	"aes256": "aes-256-cbc",
+}
+
+var ipsecP2Hash = map[string]string{
+	"null":     "null",
+	"md5":      "md5",
+	"sha-1":    "sha1",
+	"sha2-256": "sha256",
+	"sha2-512": "sha512",
+}
+
+// buildMikrotikVPN converts IKE gateways and VPNs to RouterOS IPsec. Each IKE gateway becomes a profile, a peer and
+// an identity, and each VPN becomes a proposal. IPsec policies are generated from proxy-ids (route-based VPNs) and
+// from "tunnel" policies (policy-based VPNs). Anything that can't be converted is reported on stderr. The second value
+// is true if IPsec policies were generated.
+func buildMikrotikVPN(vpn VPNConfig, policies []Policy, objects Objects, interfaces Interfaces, ifmap InterfaceMap) (string, bool) {
+	if len(vpn.Gateways) == 0 && len(vpn.VPNs) == 0 {
+		return "", false
+	}
+
+	var profiles strings.Builder
+	var peers strings.Builder
+	var identities strings.Builder
+	for _, name := range vpn.GatewayNames() {
+		gw := vpn.Gateways[name]
+		for _, opt := range gw.Unsupported {
+			_, _ = fmt.Fprintln(os.Stderr, "ike gateway "+name+": "+opt+" not converted")
+		}
+		if gw.Mode == "" {
+			_, _ = fmt.Fprintln(os.Stderr, "ike gateway "+name+" not defined")
+			continue
+		}
+
+		proposals, unknown := vpn.Phase1Proposals(gw)
+		for _, p := range unknown {
+			_, _ = fmt.Fprintln(os.Stderr, "ike gateway "+name+": p1-proposal "+p+" not found")
+		}
+		profiles.WriteString(mikrotikIPsecProfile(name, proposals))
+
+		// Peers with the placeholder pre-shared key are disabled until it's set, not to come up with a known key
+		var preshared = len(proposals) == 0 || proposals[0].Auth == AuthPreshare
+
+		peers.WriteString("add name=")
+		peers.WriteString(name)
+		if gw.Dynamic {
+			_, _ = fmt.Fprintln(os.Stderr, "ike gateway "+name+": dynamic peer "+gw.Address+" converted to a passive peer")
+			peers.WriteString(" address=0.0.0.0/0 passive=yes")
+		} else {
+			peers.WriteString(" address=")
+			peers.WriteString(gw.Address)
+		}
+		peers.WriteString(" profile=")
+		peers.WriteString(name)
+		if gw.Mode == IKEModeAggressive {
+			peers.WriteString(" exchange-mode=aggressive")
+		} else {
+			peers.WriteString(" exchange-mode=main")
+		}
+		if iface, ok := interfaces[gw.OutgoingInterface]; ok && len(iface.Addresses) > 0 {
+			peers.WriteString(" local-address=")
+			peers.WriteString(iface.Addresses[0].IP.String())
+		}
+		if preshared {
+			peers.WriteString(" disabled=yes")
+		}
+		peers.WriteString("\n")
+
+		identities.WriteString("add peer=")
+		identities.WriteString(name)
+		if !preshared {
+			_, _ = fmt.Fprintln(os.Stderr, "ike gateway "+name+": "+proposals[0].Auth+" authentication needs a certificate")
+			identities.WriteString(" auth-method=digital-signature")
+		} else {
+			_, _ = fmt.Fprintln(os.Stderr, "ike gateway "+name+": pre-shared key must be set, then the peer and identity enabled")
+			identities.WriteString(" auth-method=pre-shared-key secret=\"CHANGE-ME\"")
+		}
+		if gw.LocalID != "" {
+			identities.WriteString(" my-id=fqdn:")
+			identities.WriteString(gw.LocalID)
+		}
+		if preshared {
+			identities.WriteString(" disabled=yes")
+		}
+		identities.WriteString("\n")
+	}
+
+	var proposals strings.Builder
+	var ipsecPolicies strings.Builder
+	for _, name := range vpn.VPNNames() {
+		v := vpn.VPNs[name]
+		for _, opt := range v.Unsupported {
+			_, _ = fmt.Fprintln(os.Stderr, "vpn "+name+": "+opt+" not converted")
+		}
+		if v.Gateway == "" {
+			_, _ = fmt.Fprintln(os.Stderr, "vpn "+name+" not defined")
+			continue
+		}
+
+		p2, unknown := vpn.Phase2Proposals(v)
+		for _, p := range unknown {
+			_, _ = fmt.Fprintln(os.Stderr, "vpn "+name+": p2-proposal "+p+" not found")
+		}
+		proposals.WriteString(mikrotikIPsecProposal(name, p2))
+
+		if v.BindInterface != "" && len(v.ProxyIDs) == 0 {
+			_, _ = fmt.Fprintln(os.Stderr, "vpn "+name+": route-based VPN bound to "+v.BindInterface+
+				" without proxy-id, IPsec policies must be created by hand")
+		}
+		for _, proxy := range v.ProxyIDs {
+			if strings.ToLower(proxy.Service) != "any" {
+				_, _ = fmt.Fprintln(os.Stderr, "vpn "+name+": proxy-id service "+proxy.Service+" not converted")
+			}
+			ipsecPolicies.WriteString(mikrotikIPsecPolicy(v, proxy.Local, proxy.Remote, p2))
+		}
+	}
+
+	// Policy-based VPNs: the local side is the zone which is not the one of the gateway outgoing interface
+	var seen = make(map[string]int8)
+	for _, p := range policies {
+		if p.Disabled || p.Action != ActionTunnel {
+			continue
+		}
+		v, ok := vpn.VPNs[p.VPN]
+		if !ok || v.Gateway == "" {
+			_, _ = fmt.Fprintln(os.Stderr, "policy "+fmt.Sprint(p.ID)+": vpn "+p.VPN+" not found")
+			continue
+		}
+		p2, _ := vpn.Phase2Proposals(v)
+
+		localZone, localNames, remoteZone, remoteNames := p.From, p.Sources, p.To, p.Destinations
+		if gw, ok := vpn.Gateways[v.Gateway]; ok {
+			if iface, ok := interfaces[gw.OutgoingInterface]; ok && iface.Zone == p.From {
+				localZone, localNames, remoteZone, remoteNames = p.To, p.Destinations, p.From, p.Sources
+			}
+		}
+
+		for _, localName := range localNames {
+			_, locals := objects.Lookup(localZone, localName)
+			for _, remoteName := range remoteNames {
+				_, remotes := objects.Lookup(remoteZone, remoteName)
+				for _, local := range locals {
+					for _, remote := range remotes {
+						key := v.Name + "|" + local.String() + "|" + remote.String()
+						if _, ok := seen[key]; ok {
+							continue
+						}
+						seen[key] = 1
+						ipsecPolicies.WriteString(mikrotikIPsecPolicy(v, local, remote, p2))
+					}
+				}
+			}
+		}
+	}
+
+	var ret strings.Builder
+	writeSection(&ret, "/ip ipsec profile", profiles.String())
+	writeSection(&ret, "/ip ipsec proposal", proposals.String())
+	writeSection(&ret, "/ip ipsec peer", peers.String())
+	writeSection(&ret, "/ip ipsec identity", identities.String())
+	writeSection(&ret, "/ip ipsec policy", ipsecPolicies.String())
+	return ret.String(), ipsecPolicies.Len() > 0
+}
+
+func mikrotikIPsecProfile(name string, proposals []Proposal) string {
+	var ret strings.Builder
+	ret.WriteString("add name=")
+	ret.WriteString(name)
+	if len(proposals) == 0 {
+		ret.WriteString("\n")
+		return ret.String()
+	}
+
+	var groups []string
+	var encryptions []string
+	for _, p := range proposals {
+		if g, ok := ipsecDHGroups[p.DHGroup]; ok {
+			groups = appendUnique(groups, g)
+		} else {
+			_, _ = fmt.Fprintln(os.Stderr, "p1-proposal "+p.Name+": DH group "+fmt.Sprint(p.DHGroup)+" not supported")
+		}
+		if e, ok := ipsecP1Encryption[p.Encryption]; ok {
+			encryptions = appendUnique(encryptions, e)
+		}
+		if p.Hash != proposals[0].Hash {
+			_, _ = fmt.Fprintln(os.Stderr, "p1-proposal "+p.Name+": only hash "+proposals[0].Hash+" is used")
+		}
+	}
+
+	ret.WriteString(" dh-group=")
+	ret.WriteString(strings.Join(groups, ","))
+	ret.WriteString(" enc-algorithm=")
+	ret.WriteString(strings.Join(encryptions, ","))
+	if h, ok := ipsecP1Hash[proposals[0].Hash]; ok {
+		ret.WriteString(" hash-algorithm=")
+		ret.WriteString(h)
+	}
+	ret.WriteString(" lifetime=")
+	ret.WriteString(mikrotikDuration(proposals[0].Lifetime))
+	ret.WriteString("\n")
+	return ret.String()
+}
+
+func mikrotikIPsecProposal(name string, proposals []Proposal) string {
+	var ret strings.Builder
+	ret.WriteString("add name=")
+	ret.WriteString(name)
+	if len(proposals) == 0 {
+		ret.WriteString("\n")
+		return ret.String()
+	}
+
+	var hashes []string
+	var encryptions []string
+	for _, p := range proposals {
+		if h, ok := ipsecP2Hash[p.Hash]; ok {
+			hashes = appendUnique(hashes, h)
+		} else {
+			_, _ = fmt.Fprintln(os.Stderr, "p2-proposal "+p.Name+": hash "+p.Hash+" not supported")
+		}
+		if e, ok := ipsecP2Encryption[p.Encryption]; ok {
+			encryptions = appendUnique(encryptions, e)
+		}
+		if p.DHGroup != proposals[0].DHGroup {
+			_, _ = fmt.Fprintln(os.Stderr, "p2-proposal "+p.Name+": only the PFS group of "+proposals[0].Name+" is used")
+		}
+	}
+
+	ret.WriteString(" auth-algorithms=")
+	ret.WriteString(strings.Join(hashes, ","))
+	ret.WriteString(" enc-algorithms=")
+	ret.WriteString(strings.Join(encryptions, ","))
+	ret.WriteString(" pfs-group=")
+	if g, ok := ipsecDHGroups[proposals[0].DHGroup]; ok {
+		ret.WriteString(g)
+	} else {
+		ret.WriteString("none")
+	}
+	ret.WriteString(" lifetime=")
+	ret.WriteString(mikrotikDuration(proposals[0].Lifetime))
+	ret.WriteString("\n")
+	return ret.String()
+}
+
+func mikrotikIPsecPolicy(v *VPN, local *net.IPNet, remote *net.IPNet, proposals []Proposal) string {
+	var ret strings.Builder
+	ret.WriteString("add peer=")
+	ret.WriteString(v.Gateway)
+	ret.WriteString(" tunnel=yes src-address=")
+	ret.WriteString(local.String())
+	ret.WriteString(" dst-address=")
+	ret.WriteString(remote.String())
+	if len(proposals) > 0 && proposals[0].Protocol == "ah" {
+		ret.WriteString(" ipsec-protocols=ah")
+	}
+	ret.WriteString(" proposal=")
+	ret.WriteString(v.Name)
+	ret.WriteString(" comment=\"VPN ")
+	ret.WriteString(v.Name)
+	ret.WriteString("\"\n")
+	return ret.String()
+}
+
+// mikrotikDuration formats seconds using the largest RouterOS time unit which represents them exactly.
+func mikrotikDuration(seconds int) string {
+	switch {
+	case seconds%86400 == 0:
+		return fmt.Sprint(seconds/86400, "d")
+	case seconds%3600 == 0:
+		return fmt.Sprint(seconds/3600, "h")
+	case seconds%60 == 0:
+		return fmt.Sprint(seconds/60, "m")
+	default:
+		return fmt.Sprint(seconds, "s")
+	}
+}
+
+// writeSection writes a RouterOS menu followed by its commands, if there are any.
+func writeSection(w *strings.Builder, menu string, commands string) {
+	if commands == "" {
+		return
+	}
+	w.WriteString(menu)
+	w.WriteString("\n")
+	w.WriteString(commands)
+	w.WriteString("\n\n")
+}
+
+func appendUnique(list []string, s string) []string {
+	for _, item := range list {
+		if item == s {
+			return list
+		}
+	}
+	return append(list, s)
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"sort"
+)
+
+type Config struct {
+	Policies []Policy
+	Objects  Objects
+	Services Services
+
+	// Routing
+	VRouters []string
+	Routes   []Route
+
+	// Interfaces
+	Interfaces Interfaces
+
+	// IPsec
+	VPN VPNConfig
+
+	// Management
+	Admin AdminConfig
+
+	// Attack protection
+	Screens Screens
+
+	// MIP, VIP and DIP
+	NAT NATConfig
+}
+
+// Zones returns the names of all zones with at least one interface, policy or screen option.
+func (c *Config) Zones() []string {
+	var zones = make(map[string]int8)
+	for _, i := range c.Interfaces {
+		if i.Zone != "" {
+			zones[i.Zone] = 1
+		}
+	}
+	for _, p := range c.Policies {
+		zones[p.From] = 1
+		zones[p.To] = 1
+	}
+	for z := range c.Screens {
+		zones[z] = 1
+	}
+
+	var ret = make([]string, 0, len(zones))
+	for z := range zones {
+		ret = append(ret, z)
+	}
+	sort.Strings(ret)
+	return ret
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// diffConfigs reports the differences between two configurations: added, removed, modified and moved policies (by ID,
+// with the changed fields), added and removed address objects, changed addresses and group members, and changed
+// services.
+func diffConfigs(before Config, after Config) []string {
+	var ret []string
+	ret = append(ret, diffPolicies(before.Policies, after.Policies)...)
+	ret = append(ret, diffObjects(before.Objects, after.Objects)...)
+	ret = append(ret, diffServices(before.Services, after.Services)...)
+	return ret
+}
+
+// diffRouterOS returns the address list and filter rule commands which turn the conversion of the policies from or to
+// the zone of a configuration (before) into the conversion of the other one (after), like sync.
+func diffRouterOS(before Config, after Config, zone string, opts MikrotikOptions) string {
+	var rsc [2][]RscCommand
+	for idx, cfg := range [2]Config{before, after} {
+		script := buildMikrotik(filterPolicies(cfg.Policies, zone), cfg.Objects, cfg.Services, opts)
+		rsc[idx] = parseRsc(strings.NewReader(tagMikrotik(script, opts.Tag, false)))
+	}
+	return syncMikrotik(rsc[0], rsc[1])
+}
+
+func diffPolicies(before []Policy, after []Policy) []string {
+	var oldByID = make(map[int]*Policy, len(before))
+	for idx := range before {
+		oldByID[before[idx].ID] = &before[idx]
+	}
+	var newByID = make(map[int]*Policy, len(after))
+	for idx := range after {
+		newByID[after[idx].ID] = &after[idx]
+	}
+
+	var ret []string
+	for idx := range before {
+		if _, ok := newByID[before[idx].ID]; !ok {
+			ret = append(ret, "removed policy "+policySummary(&before[idx]))
+		}
+	}
+
+	var moved = movedPolicies(before, after)
+	for idx := range after {
+		p := &after[idx]
+		q, ok := oldByID[p.ID]
+		if !ok {
+			ret = append(ret, "added policy "+policySummary(p))
+			continue
+		}
+		if !q.Equals(p) {
+			for _, change := range policyChanges(q, p) {
+				ret = append(ret, fmt.Sprintf("policy %d: %s", p.ID, change))
+			}
+		}
+		if previous, ok := moved[p.ID]; ok && previous == 0 {
+			ret = append(ret, fmt.Sprintf("policy %d: moved to the top of %s -> %s", p.ID, p.From, p.To))
+		} else if ok {
+			ret = append(ret, fmt.Sprintf("policy %d: moved after policy %d", p.ID, previous))
+		}
+	}
+	return ret
+}
+
+// movedPolicies returns the policies whose position changed within their zone pair, with the ID of the previous
+// policy in the new order (0 for the first one). The policies in both configurations that keep their relative order
+// are the longest increasing subsequence of their old positions; the others are moved.
+func movedPolicies(before []Policy, after []Policy) map[int]int {
+	var oldPosition = make(map[int]int, len(before))
+	for idx, p := range before {
+		oldPosition[p.ID] = idx
+	}
+
+	var pairs = make(map[string][]Policy)
+	var pairNames []string
+	for _, p := range after {
+		if q, ok := oldPosition[p.ID]; ok && before[q].From == p.From && before[q].To == p.To {
+			key := p.From + "/" + p.To
+			if _, ok := pairs[key]; !ok {
+				pairNames = append(pairNames, key)
+			}
+			pairs[key] = append(pairs[key], p)
+		}
+	}
+
+	var ret = make(map[int]int)
+	for _, key := range pairNames {
+		policies := pairs[key]
+
+		var positions = make([]int, len(policies))
+		for idx, p := range policies {
+			positions[idx] = oldPosition[p.ID]
+		}
+		var kept = longestIncreasing(positions)
+		for idx, p := range policies {
+			if kept[idx] {
+				continue
+			}
+			ret[p.ID] = 0
+			if idx > 0 {
+				ret[p.ID] = policies[idx-1].ID
+			}
+		}
+	}
+	return ret
+}
+
+func policySummary(p *Policy) string {
+	var ret = fmt.Sprintf("%d", p.ID)
+	if p.Name != "" {
+		ret += " \"" + p.Name + "\""
+	}
+	ret += fmt.Sprintf(" %s -> %s: %s -> %s %s %s", p.From, p.To, strings.Join(p.Sources, ","),
+		strings.Join(p.Destinations, ","), strings.Join(p.Services, ","), p.Action)
+	if p.Disabled {
+		ret += " (disabled)"
+	}
+	return ret
+}
+
+// policyChanges lists the changed fields of a policy, as "field before -> after", or with the added and removed entries
+// for addresses and services.
+func policyChanges(before *Policy, after *Policy) []string {
+	var ret []string
+	for _, f := range []struct {
+		name          string
+		before, after interface{}
+	}{
+		{"name", before.Name, after.Name},
+		{"disabled", before.Disabled, after.Disabled},
+		{"from", before.From, after.From},
+		{"to", before.To, after.To},
+		{"application", before.Application, after.Application},
+		{"nat", before.NAT, after.NAT},
+		{"nat address", before.NATAddress, after.NATAddress},
+		{"nat port", before.NATPort, after.NATPort},
+		{"action", before.Action, after.Action},
+		{"vpn", before.VPN, after.VPN},
+		{"pair policy", before.PairPolicy, after.PairPolicy},
+		{"log", before.Log, after.Log},
+		{"log session init", before.LogInit, after.LogInit},
+	} {
+		if f.before != f.after {
+			ret = append(ret, fmt.Sprintf("%s %v -> %v", f.name, f.before, f.after))
+		}
+	}
+
+	for _, f := range []struct {
+		name          string
+		before, after []string
+	}{
+		{"sources", before.Sources, after.Sources},
+		{"destinations", before.Destinations, after.Destinations},
+		{"services", before.Services, after.Services},
+	} {
+		if !stringSliceEqual(f.before, f.after) {
+			ret = append(ret, f.name+" "+memberChanges(f.before, f.after))
+		}
+	}
+	return ret
+}
+
+// memberChanges formats the entries added to and removed from a list, like "+a +b -c".
+func memberChanges(before []string, after []string) string {
+	var ret []string
+	for _, s := range after {
+		if !containsString(before, s) {
+			ret = append(ret, "+"+s)
+		}
+	}
+	for _, s := range before {
+		if !containsString(after, s) {
+			ret = append(ret, "-"+s)
+		}
+	}
+	return strings.Join(ret, " ")
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func diffObjects(before Objects, after Objects) []string {
+	var zones = before.Zones()
+	for _, zone := range after.Zones() {
+		zones = appendUnique(zones, zone)
+	}
+	sort.Strings(zones)
+
+	var ret []string
+	for _, zone := range zones {
+		var names = before.Names(zone)
+		for _, name := range after.Names(zone) {
+			names = appendUnique(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			o, inOld := before[zone][name]
+			n, inNew := after[zone][name]
+			switch {
+			case !inNew:
+				ret = append(ret, "removed "+objectSummary(zone, name, o))
+			case !inOld:
+				ret = append(ret, "added "+objectSummary(zone, name, n))
+			case o.Group != n.Group:
+				ret = append(ret, "object "+zone+"/"+name+": "+objectSummary(zone, name, o)+" -> "+
+					objectSummary(zone, name, n))
+			case o.Group && !stringSliceEqual(o.GroupMembers, n.GroupMembers):
+				ret = append(ret, "group "+zone+"/"+name+": members "+memberChanges(o.GroupMembers, n.GroupMembers))
+			case !o.Group && o.Address.String() != n.Address.String():
+				ret = append(ret, "object "+zone+"/"+name+": address "+o.Address.String()+" -> "+n.Address.String())
+			}
+		}
+	}
+	return ret
+}
+
+func objectSummary(zone string, name string, o *PolicyObject) string {
+	if o.Group {
+		return "group " + zone + "/" + name + " (" + strings.Join(o.GroupMembers, ",") + ")"
+	}
+	return "object " + zone + "/" + name + " " + o.Address.String()
+}
+
+func diffServices(before Services, after Services) []string {
+	var names []string
+	for name := range before {
+		names = append(names, name)
+	}
+	for name := range after {
+		if _, ok := before[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var ret []string
+	for _, name := range names {
+		o, inOld := before[name]
+		n, inNew := after[name]
+		switch {
+		case !inNew:
+			ret = append(ret, "removed service "+name+" "+serviceListString(o))
+		case !inOld:
+			ret = append(ret, "added service "+name+" "+serviceListString(n))
+		case serviceListString(o) != serviceListString(n):
+			ret = append(ret, "service "+name+": "+serviceListString(o)+" -> "+serviceListString(n))
+		}
+	}
+	return ret
+}
+
+// serviceListString formats the entries of a service, sorted, like "tcp dst-port 80, udp dst-port 53".
+func serviceListString(sl ServiceList) string {
+	var entries = make([]string, 0, len(sl))
+	for _, s := range sl {
+		var entry = s.Protocol
+		if entry == "" {
+			entry = "any"
+		}
+		switch {
+		case s.Protocol == "icmp" && s.IcmpType != 0:
+			entry += fmt.Sprintf(" type %d", s.IcmpType)
+		case s.Protocol == "tcp" || s.Protocol == "udp":
+			src := Range{uint32(s.SrcPortStart), uint32(s.SrcPortEnd)}
+			if src != fullPortRange {
+				entry += " src-port " + portRangeString(src)
+			}
+			entry += " dst-port " + portRangeString(Range{uint32(s.DstPortStart), uint32(s.DstPortEnd)})
+		}
+		entries = appendUnique(entries, entry)
+	}
+	sort.Strings(entries)
+	return strings.Join(entries, ", ")
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDiffMovedPolicy(t *testing.T) {
+	before := parse(strings.NewReader(syncAddresses+syncPolicy1+syncPolicy2+syncPolicy3), "before.cfg")
+	after := parse(strings.NewReader(syncAddresses+syncPolicy2+syncPolicy1+syncPolicy3), "after.cfg")
+
+	if got, want := diffConfigs(before, after), []string{"policy 2: moved to the top of Trust -> DMZ"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	got := diffRouterOS(before, after, "", MikrotikOptions{})
+	want := `/ip firewall filter
+remove [find chain=Trust__DMZ comment="ID: 2 - Any -> Any"]
+add chain=Trust__DMZ protocol=tcp dst-port=80 action=accept comment="ID: 2 - Any -> Any" place-before=[:pick [find chain=Trust__DMZ comment="ID: 1 - h1 -> s1"] 0]
+`
+	if strings.TrimSpace(got) != strings.TrimSpace(want) {
+		t.Errorf("routeros: got\n%s\nwant\n%s", got, want)
+	}
+}
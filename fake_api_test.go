@@ -0,0 +1,294 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// fakeRouterOS is an in-process RouterOS API server keeping the menus in memory, to test push offline. It supports
+// login, print (with "?key=value" queries and .proplist), add (with place-before), set, unset and remove.
+type fakeRouterOS struct {
+	// FailAt makes the Nth change (add, set, unset or remove) fail with a trap, 0 to never fail
+	FailAt int
+
+	user     string
+	password string
+	listener net.Listener
+
+	mu      sync.Mutex
+	menus   map[string][]map[string]string
+	nextID  int
+	changes int
+}
+
+// startFakeRouterOS starts a fake RouterOS API server on a random local port.
+func startFakeRouterOS(user string, password string) (*fakeRouterOS, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	var f = &fakeRouterOS{user: user, password: password, listener: listener, menus: make(map[string][]map[string]string), nextID: 1}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	return f, nil
+}
+
+func (f *fakeRouterOS) Address() string {
+	return f.listener.Addr().String()
+}
+
+func (f *fakeRouterOS) Close() error {
+	return f.listener.Close()
+}
+
+// load adds the items of the add commands of a RouterOS script, like an export of the router, and applies the set
+// commands of single item menus (like /ip settings).
+func (f *fakeRouterOS) load(commands []RscCommand) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, cmd := range commands {
+		var path = strings.ReplaceAll(cmd.Menu, " ", "/")
+		var item = make(map[string]string, len(cmd.Args))
+		for k, v := range cmd.Args {
+			item[k] = normalizeRscValue(k, v)
+		}
+
+		switch {
+		case cmd.Verb == "add":
+			// Flags without value, like "fib" in /routing table
+			for _, flag := range strings.Fields(cmd.Find) {
+				item[flag] = ""
+			}
+			f.add(path, item)
+		case cmd.Verb == "set" && cmd.Find == "":
+			if len(f.menus[path]) == 0 {
+				f.add(path, make(map[string]string))
+			}
+			for k, v := range item {
+				f.menus[path][0][k] = v
+			}
+		}
+	}
+}
+
+// Export prints the items of every menu as a RouterOS script, with the properties sorted by name.
+func (f *fakeRouterOS) Export() string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var paths = make([]string, 0, len(f.menus))
+	for path := range f.menus {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	var ret strings.Builder
+	for _, path := range paths {
+		var commands strings.Builder
+		for _, item := range f.menus[path] {
+			var keys = make([]string, 0, len(item))
+			for k := range item {
+				if k != ".id" {
+					keys = append(keys, k)
+				}
+			}
+			sort.Strings(keys)
+
+			commands.WriteString("add")
+			for _, k := range keys {
+				commands.WriteString(" " + k + "=" + rscQuote(item[k]))
+			}
+			commands.WriteString("\n")
+		}
+		writeSection(&ret, "/"+strings.ReplaceAll(path[1:], "/", " "), commands.String())
+	}
+	return ret.String()
+}
+
+func (f *fakeRouterOS) serve(conn net.Conn) {
+	defer func() { _ = conn.Close() }()
+
+	var r = bufio.NewReader(conn)
+	var loggedIn = false
+	for {
+		sentence, err := readAPISentence(r)
+		if err != nil {
+			return
+		}
+		if len(sentence) == 0 {
+			continue
+		}
+
+		var reply [][]string
+		switch {
+		case sentence[0] == "/login":
+			attrs := apiAttributes(sentence[1:])
+			if attrs["name"] == f.user && attrs["password"] == f.password {
+				loggedIn = true
+				reply = [][]string{{"!done"}}
+			} else {
+				reply = [][]string{{"!trap", "=message=invalid user name or password (6)"}, {"!done"}}
+			}
+		case !loggedIn:
+			reply = [][]string{{"!trap", "=message=not logged in"}, {"!done"}}
+		default:
+			reply = f.handle(sentence)
+		}
+
+		for _, s := range reply {
+			if err := writeAPISentence(conn, s); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// handle runs a command, returning the reply sentences.
+func (f *fakeRouterOS) handle(sentence []string) [][]string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var slash = strings.LastIndex(sentence[0], "/")
+	var path, verb = sentence[0][:slash], sentence[0][slash+1:]
+	var attrs = apiAttributes(sentence[1:])
+	var trap = func(message string) [][]string {
+		return [][]string{{"!trap", "=message=" + message}, {"!done"}}
+	}
+
+	if verb != "print" {
+		f.changes++
+		if f.changes == f.FailAt {
+			return trap("failure injected")
+		}
+	}
+
+	switch verb {
+	case "print":
+		return f.print(path, attrs[".proplist"], sentence[1:])
+	case "add":
+		for k, v := range attrs {
+			attrs[k] = normalizeRscValue(k, v)
+		}
+		if placeBefore := attrs["place-before"]; placeBefore != "" && f.index(path, placeBefore) < 0 {
+			return trap("no such item")
+		}
+		return [][]string{{"!done", "=ret=" + f.add(path, attrs)}}
+	case "set", "unset":
+		var id = attrs[".id"]
+		if verb == "unset" {
+			id = attrs["numbers"]
+		}
+		var item map[string]string
+		if id == "" {
+			// Single item menus like /ip settings
+			if len(f.menus[path]) == 0 {
+				f.add(path, make(map[string]string))
+			}
+			item = f.menus[path][0]
+		} else if idx := f.index(path, id); idx >= 0 {
+			item = f.menus[path][idx]
+		} else {
+			return trap("no such item")
+		}
+
+		if verb == "unset" {
+			delete(item, attrs["value-name"])
+			return [][]string{{"!done"}}
+		}
+		for k, v := range attrs {
+			if k != ".id" {
+				item[k] = normalizeRscValue(k, v)
+			}
+		}
+		return [][]string{{"!done"}}
+	case "remove":
+		for _, id := range strings.Split(attrs[".id"], ",") {
+			idx := f.index(path, id)
+			if idx < 0 {
+				return trap("no such item")
+			}
+			f.menus[path] = append(f.menus[path][:idx], f.menus[path][idx+1:]...)
+		}
+		return [][]string{{"!done"}}
+	}
+	return trap("no such command")
+}
+
+func (f *fakeRouterOS) print(path string, proplist string, words []string) [][]string {
+	var props map[string]bool
+	if proplist != "" {
+		props = make(map[string]bool)
+		for _, p := range strings.Split(proplist, ",") {
+			props[p] = true
+		}
+	}
+
+	var ret [][]string
+	for _, item := range f.menus[path] {
+		var match = true
+		for _, w := range words {
+			if strings.HasPrefix(w, "?") {
+				kv := strings.SplitN(w[1:], "=", 2)
+				if len(kv) != 2 || item[kv[0]] != kv[1] {
+					match = false
+				}
+			}
+		}
+		if !match {
+			continue
+		}
+
+		var keys = make([]string, 0, len(item))
+		for k := range item {
+			if props == nil || props[k] {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		var re = []string{"!re"}
+		for _, k := range keys {
+			re = append(re, "="+k+"="+item[k])
+		}
+		ret = append(ret, re)
+	}
+	return append(ret, []string{"!done"})
+}
+
+// add adds an item before the place-before item, if any, returning its ID.
+func (f *fakeRouterOS) add(path string, item map[string]string) string {
+	var id = fmt.Sprintf("*%X", f.nextID)
+	f.nextID++
+
+	var placeBefore = item["place-before"]
+	delete(item, "place-before")
+	item[".id"] = id
+
+	var idx = f.index(path, placeBefore)
+	if placeBefore == "" || idx < 0 {
+		f.menus[path] = append(f.menus[path], item)
+		return id
+	}
+	f.menus[path] = append(f.menus[path][:idx], append([]map[string]string{item}, f.menus[path][idx:]...)...)
+	return id
+}
+
+func (f *fakeRouterOS) index(path string, id string) int {
+	for idx, item := range f.menus[path] {
+		if item[".id"] == id {
+			return idx
+		}
+	}
+	return -1
+}
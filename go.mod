@@ -1,3 +1,5 @@
 module gitlab.com/enrico204/netscreen-to-mikrotik
 
 go 1.17
+
+require gopkg.in/yaml.v3 v3.0.1
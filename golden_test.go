@@ -0,0 +1,60 @@
+package main
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+// TestGolden converts the testdata configurations several times, and compares each conversion with the expected
+// output, to catch both changes and non reproducible output (e.g. from map iteration).
+func TestGolden(t *testing.T) {
+	for _, tc := range []struct {
+		config, ifmap, golden string
+	}{
+		{"testdata/branch.cfg", "testdata/branch.ifmap", "testdata/branch.rsc"},
+		{"testdata/services.cfg", "", "testdata/services.rsc"},
+		{"testdata/model.yaml", "", "testdata/model.rsc"},
+	} {
+		golden, err := os.ReadFile(tc.golden)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ifmap, err := readInterfaceMap(tc.ifmap)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for run := 1; run <= 5; run++ {
+			fp, err := os.Open(tc.config)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cfg, err := loadConfig(fp, tc.config, FormatAuto)
+			_ = fp.Close()
+			if err != nil {
+				t.Fatalf("%s: %v", tc.config, err)
+			}
+
+			// The command prints the script with a trailing newline
+			got := convertConfig(cfg, "", ifmap, MikrotikOptions{}) + "\n"
+			if got != string(golden) {
+				t.Fatalf("%s, run %d: output differs from %s at line %d", tc.config, run, tc.golden, diffLine(got, string(golden)))
+			}
+		}
+	}
+}
+
+// diffLine returns the number of the first line which differs between the two texts.
+func diffLine(a, b string) int {
+	al, bl := strings.Split(a, "\n"), strings.Split(b, "\n")
+	for idx := 0; idx < len(al) && idx < len(bl); idx++ {
+		if al[idx] != bl[idx] {
+			return idx + 1
+		}
+	}
+	if len(al) < len(bl) {
+		return len(al) + 1
+	}
+	return len(bl) + 1
+}
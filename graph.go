@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// graphTopServices is the number of services listed on each edge of the zone graph
+const graphTopServices = 3
+
+// ZoneEdge is the summary of the enabled policies of a zone pair: the number of permitting (permit and tunnel) and
+// denying (deny and reject) policies, and the services used by the most policies.
+type ZoneEdge struct {
+	From     string
+	To       string
+	Permit   int
+	Deny     int
+	Services []string
+}
+
+// Label returns the edge label, like "3 permit, 1 deny" followed by the top services on a new line.
+func (e ZoneEdge) Label() string {
+	var counts []string
+	if e.Permit > 0 {
+		counts = append(counts, fmt.Sprint(e.Permit, " permit"))
+	}
+	if e.Deny > 0 {
+		counts = append(counts, fmt.Sprint(e.Deny, " deny"))
+	}
+	return strings.Join(counts, ", ") + "\n" + strings.Join(e.Services, ", ")
+}
+
+// Color returns the edge color: green if all the policies permit, red if all of them deny, orange otherwise.
+func (e ZoneEdge) Color() string {
+	switch {
+	case e.Deny == 0:
+		return "green"
+	case e.Permit == 0:
+		return "red"
+	}
+	return "orange"
+}
+
+// zoneEdges summarizes the enabled policies by zone pair, in order of first appearance.
+func zoneEdges(policies []Policy) []ZoneEdge {
+	var ret []ZoneEdge
+	var index = make(map[string]int)
+	var services = make(map[string]map[string]int)
+	for _, p := range policies {
+		if p.Disabled {
+			continue
+		}
+
+		pair := p.From + "__" + p.To
+		idx, ok := index[pair]
+		if !ok {
+			idx = len(ret)
+			index[pair] = idx
+			ret = append(ret, ZoneEdge{From: p.From, To: p.To})
+			services[pair] = make(map[string]int)
+		}
+		if p.Permits() {
+			ret[idx].Permit++
+		} else {
+			ret[idx].Deny++
+		}
+		for _, s := range p.Services {
+			services[pair][s]++
+		}
+	}
+
+	for idx := range ret {
+		counts := services[ret[idx].From+"__"+ret[idx].To]
+		var names = make([]string, 0, len(counts))
+		for name := range counts {
+			names = append(names, name)
+		}
+		sort.Slice(names, func(i, j int) bool {
+			if counts[names[i]] != counts[names[j]] {
+				return counts[names[i]] > counts[names[j]]
+			}
+			return names[i] < names[j]
+		})
+		if len(names) > graphTopServices {
+			names = names[:graphTopServices]
+		}
+		ret[idx].Services = names
+	}
+	return ret
+}
+
+// graphDOT renders the zones and the zone pair edges as a Graphviz graph. Deny-only edges are dashed.
+func graphDOT(zones []string, edges []ZoneEdge) string {
+	var ret strings.Builder
+	ret.WriteString("digraph zones {\n")
+	ret.WriteString("  rankdir=LR;\n")
+	ret.WriteString("  node [shape=box, style=rounded];\n")
+	for _, zone := range zones {
+		ret.WriteString("  " + dotQuote(zone) + ";\n")
+	}
+	for _, e := range edges {
+		var style = "solid"
+		if e.Permit == 0 {
+			style = "dashed"
+		}
+		ret.WriteString(fmt.Sprintf("  %s -> %s [label=%s, color=%s, fontcolor=%s, style=%s];\n", dotQuote(e.From),
+			dotQuote(e.To), dotQuote(e.Label()), e.Color(), e.Color(), style))
+	}
+	ret.WriteString("}\n")
+	return ret.String()
+}
+
+// dotQuote quotes a Graphviz ID, with the newlines as line breaks.
+func dotQuote(s string) string {
+	return "\"" + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + "\""
+}
+
+// graphMermaid renders the zones and the zone pair edges as a Mermaid flowchart. Zones are numbered, as Mermaid node
+// IDs can't hold every zone name.
+func graphMermaid(zones []string, edges []ZoneEdge) string {
+	var ids = make(map[string]string, len(zones))
+	var ret strings.Builder
+	ret.WriteString("flowchart LR\n")
+	for idx, zone := range zones {
+		ids[zone] = fmt.Sprint("z", idx)
+		ret.WriteString("  " + ids[zone] + "[" + mermaidQuote(zone) + "]\n")
+	}
+	for _, e := range edges {
+		var arrow = "-->"
+		if e.Permit == 0 {
+			arrow = "-.->"
+		}
+		ret.WriteString("  " + ids[e.From] + " " + arrow + "|" + mermaidQuote(e.Label()) + "| " +
+			ids[e.To] + "\n")
+	}
+	for idx, e := range edges {
+		ret.WriteString(fmt.Sprintf("  linkStyle %d stroke:%s,color:%s\n", idx, e.Color(), e.Color()))
+	}
+	return ret.String()
+}
+
+// mermaidQuote quotes a Mermaid label, with the newlines as line breaks.
+func mermaidQuote(s string) string {
+	return "\"" + strings.NewReplacer(`"`, "#quot;", "\n", "<br>").Replace(s) + "\""
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestZoneEdges(t *testing.T) {
+	var policies = []Policy{
+		{ID: 1, From: "DMZ", To: "Trust", Services: []string{"DNS"}, Action: ActionDeny},
+		{ID: 2, From: "Trust", To: "DMZ", Services: []string{"SMTP", "HTTP"}, Action: ActionPermit},
+		{ID: 3, From: "Trust", To: "DMZ", Services: []string{"HTTP", "FTP"}, Action: ActionDeny},
+		{ID: 4, From: "Trust", To: "DMZ", Services: []string{"DNS"}, Action: ActionTunnel},
+		{ID: 5, From: "Untrust", To: "DMZ", Services: []string{"HTTP"}, Action: ActionPermit, Disabled: true},
+		{ID: 6, From: "DMZ", To: "Trust", Services: []string{"ANY"}, Action: ActionPermit},
+	}
+	var want = []ZoneEdge{
+		{From: "DMZ", To: "Trust", Permit: 1, Deny: 1, Services: []string{"ANY", "DNS"}},
+		// HTTP is used twice, then the ties are sorted by name
+		{From: "Trust", To: "DMZ", Permit: 2, Deny: 1, Services: []string{"HTTP", "DNS", "FTP"}},
+	}
+	if got := zoneEdges(policies); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// defaultSourcePort is the source port of simulated packets when not specified.
+const defaultSourcePort = 1024
+
+// PacketTest is a row of a packet test file: a packet between two zones and its expected verdict.
+type PacketTest struct {
+	Line     int
+	From     string
+	To       string
+	Packet   Packet
+	Expected string
+}
+
+type PacketTestResult struct {
+	Test     PacketTest
+	ScreenOS Verdict
+	RouterOS Verdict
+}
+
+func (r *PacketTestResult) Passed() bool {
+	return r.ScreenOS.Action == r.Test.Expected && r.RouterOS.Action == r.Test.Expected
+}
+
+// normalizeAction converts ScreenOS and RouterOS action names to verdicts.
+func normalizeAction(action string) (string, bool) {
+	switch strings.ToLower(strings.TrimSpace(action)) {
+	case ActionPermit, ActionTunnel, VerdictAccept, "allow":
+		return VerdictAccept, true
+	case ActionDeny, VerdictDrop, ActionDefaultDeny:
+		return VerdictDrop, true
+	case ActionReject:
+		return VerdictReject, true
+	}
+	return "", false
+}
+
+// parsePacketTests reads a CSV with from_zone,to_zone,src,dst,proto,dport,expected_action rows. A header row is
+// skipped.
+func parsePacketTests(reader io.Reader) ([]PacketTest, error) {
+	var r = csv.NewReader(reader)
+	r.FieldsPerRecord = 7
+	r.Comment = '#'
+	r.TrimLeadingSpace = true
+
+	var ret []PacketTest
+	for {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+		line, _ := r.FieldPos(0)
+		if strings.EqualFold(record[0], "from_zone") {
+			continue
+		}
+
+		dport, err := strconv.Atoi(record[5])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid port %q", line, record[5])
+		}
+		pkt, err := newPacket(record[2], record[3], strings.ToLower(record[4]), defaultSourcePort, dport)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		expected, ok := normalizeAction(record[6])
+		if !ok {
+			return nil, fmt.Errorf("line %d: invalid action %q", line, record[6])
+		}
+		ret = append(ret, PacketTest{Line: line, From: record[0], To: record[1], Packet: pkt, Expected: expected})
+	}
+	return ret, nil
+}
+
+// runPacketTests evaluates each test packet against the ScreenOS policies and the RouterOS filter rules.
+func runPacketTests(tests []PacketTest, policies []Policy, objects Objects, services Services, firewall MikrotikFirewall) ([]PacketTestResult, error) {
+	var ret = make([]PacketTestResult, 0, len(tests))
+	for _, t := range tests {
+		var result = PacketTestResult{Test: t, ScreenOS: Verdict{Box: t.Packet.Box(), Source: ActionDefaultDeny}}
+
+		trace := tracePacket(policies, objects, services, t.From, t.To, t.Packet)
+		result.ScreenOS.Action, _ = normalizeAction(trace.Action)
+		if trace.Policy != nil {
+			result.ScreenOS.Source = fmt.Sprint("policy ", trace.Policy.ID)
+		}
+
+		var err error
+		result.RouterOS, err = firewall.Trace(t.From, t.To, t.Packet)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, result)
+	}
+	return ret, nil
+}
+
+func formatPacketTestResults(results []PacketTestResult) string {
+	var ret strings.Builder
+	var w = tabwriter.NewWriter(&ret, 0, 4, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "LINE\tFROM\tTO\tPACKET\tEXPECTED\tSCREENOS\tROUTEROS\tRESULT")
+
+	var failed = 0
+	for _, r := range results {
+		var status = "PASS"
+		if !r.Passed() {
+			status = "FAIL"
+			failed++
+		}
+		_, _ = fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%s (%s)\t%s (%s)\t%s\n", r.Test.Line, r.Test.From, r.Test.To,
+			r.Test.Packet.String(), r.Test.Expected, r.ScreenOS.Action, r.ScreenOS.Source, r.RouterOS.Action,
+			r.RouterOS.Source, status)
+	}
+	_ = w.Flush()
+
+	ret.WriteString(fmt.Sprintf("\n%d tests, %d passed, %d failed\n", len(results), len(results)-failed, failed))
+	return ret.String()
+}
@@ -0,0 +1,427 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+)
+
+// Range is an inclusive range of addresses, protocol numbers or ports.
+type Range struct {
+	Lo uint32
+	Hi uint32
+}
+
+var (
+	fullAddressRange  = Range{0, 0xffffffff}
+	fullProtocolRange = Range{0, 255}
+	fullPortRange     = Range{0, 65535}
+)
+
+const (
+	protoICMP = 1
+	protoTCP  = 6
+	protoUDP  = 17
+)
+
+// Box is a set of IPv4 packet headers: the cartesian product of its ranges. For ICMP, DstPort holds the ICMP type.
+type Box struct {
+	Src     Range
+	Dst     Range
+	Proto   Range
+	SrcPort Range
+	DstPort Range
+}
+
+// Space is a set of packet headers, as a union of (possibly overlapping) boxes.
+type Space []Box
+
+// FullBox matches every packet.
+var FullBox = Box{fullAddressRange, fullAddressRange, fullProtocolRange, fullPortRange, fullPortRange}
+
+func (b *Box) dims() [5]*Range {
+	return [5]*Range{&b.Src, &b.Dst, &b.Proto, &b.SrcPort, &b.DstPort}
+}
+
+func (b Box) Intersect(c Box) (Box, bool) {
+	var ret = b
+	var cd = c.dims()
+	for i, r := range ret.dims() {
+		if cd[i].Lo > r.Lo {
+			r.Lo = cd[i].Lo
+		}
+		if cd[i].Hi < r.Hi {
+			r.Hi = cd[i].Hi
+		}
+		if r.Lo > r.Hi {
+			return Box{}, false
+		}
+	}
+	return ret, true
+}
+
+func (b Box) Contains(c Box) bool {
+	var cd = c.dims()
+	for i, r := range b.dims() {
+		if cd[i].Lo < r.Lo || cd[i].Hi > r.Hi {
+			return false
+		}
+	}
+	return true
+}
+
+// Subtract returns b minus c, as disjoint boxes.
+func (b Box) Subtract(c Box) []Box {
+	inter, ok := b.Intersect(c)
+	if !ok {
+		return []Box{b}
+	}
+	if inter == b {
+		return nil
+	}
+
+	// Peel off the parts of b outside the intersection, one dimension at a time
+	var ret []Box
+	var rest = b
+	var id = inter.dims()
+	for i := range id {
+		r := rest.dims()[i]
+		if r.Lo < id[i].Lo {
+			part := rest
+			part.dims()[i].Hi = id[i].Lo - 1
+			ret = append(ret, part)
+		}
+		if r.Hi > id[i].Hi {
+			part := rest
+			part.dims()[i].Lo = id[i].Hi + 1
+			ret = append(ret, part)
+		}
+		*rest.dims()[i] = *id[i]
+	}
+	return ret
+}
+
+// Sample returns the lowest packet in the box.
+func (b Box) Sample() Packet {
+	return Packet{
+		Src:     uint32ToIP(b.Src.Lo),
+		Dst:     uint32ToIP(b.Dst.Lo),
+		Proto:   int(b.Proto.Lo),
+		SrcPort: int(b.SrcPort.Lo),
+		DstPort: int(b.DstPort.Lo),
+	}
+}
+
+func (b Box) String() string {
+	var parts []string
+	if b.Src != fullAddressRange {
+		parts = append(parts, "src "+addressRangeString(b.Src))
+	}
+	if b.Dst != fullAddressRange {
+		parts = append(parts, "dst "+addressRangeString(b.Dst))
+	}
+	if b.Proto != fullProtocolRange {
+		if b.Proto.Lo == b.Proto.Hi {
+			parts = append(parts, protocolName(int(b.Proto.Lo)))
+		} else {
+			parts = append(parts, fmt.Sprintf("proto %d-%d", b.Proto.Lo, b.Proto.Hi))
+		}
+	}
+	if b.SrcPort != fullPortRange {
+		parts = append(parts, "sport "+portRangeString(b.SrcPort))
+	}
+	if b.DstPort != fullPortRange {
+		if b.Proto == (Range{protoICMP, protoICMP}) {
+			parts = append(parts, "type "+portRangeString(b.DstPort))
+		} else {
+			parts = append(parts, "dport "+portRangeString(b.DstPort))
+		}
+	}
+	if len(parts) == 0 {
+		return "any packet"
+	}
+	return strings.Join(parts, " ")
+}
+
+// addressRangeString formats an address range as a network when possible.
+func addressRangeString(r Range) string {
+	if r.Lo == r.Hi {
+		return uint32ToIP(r.Lo).String()
+	}
+	size := uint64(r.Hi) - uint64(r.Lo) + 1
+	if size&(size-1) == 0 && uint64(r.Lo)%size == 0 {
+		ones := 32
+		for s := size; s > 1; s >>= 1 {
+			ones--
+		}
+		return fmt.Sprintf("%s/%d", uint32ToIP(r.Lo), ones)
+	}
+	return uint32ToIP(r.Lo).String() + "-" + uint32ToIP(r.Hi).String()
+}
+
+func portRangeString(r Range) string {
+	if r.Lo == r.Hi {
+		return fmt.Sprint(r.Lo)
+	}
+	return fmt.Sprintf("%d-%d", r.Lo, r.Hi)
+}
+
+func (s Space) IsEmpty() bool {
+	return len(s) == 0
+}
+
+func (s Space) Intersect(t Space) Space {
+	var ret Space
+	for _, b := range s {
+		for _, c := range t {
+			if inter, ok := b.Intersect(c); ok {
+				ret = append(ret, inter)
+			}
+		}
+	}
+	return ret
+}
+
+func (s Space) Subtract(t Space) Space {
+	var ret = s
+	for _, c := range t {
+		var next Space
+		for _, b := range ret {
+			next = append(next, b.Subtract(c)...)
+		}
+		ret = next
+		if len(ret) == 0 {
+			break
+		}
+	}
+	return ret
+}
+
+// Split returns the parts of s inside and outside t. If the boxes of s are disjoint, so are the boxes of both parts.
+func (s Space) Split(t Space) (Space, Space) {
+	var in Space
+	var out = s
+	for _, c := range t {
+		for _, b := range out {
+			if inter, ok := b.Intersect(c); ok {
+				in = append(in, inter)
+			}
+		}
+		out = out.Subtract(Space{c})
+		if len(out) == 0 {
+			break
+		}
+	}
+	return in, out
+}
+
+// ContainedIn returns true if every packet of s is also in t.
+func (s Space) ContainedIn(t Space) bool {
+	return s.Subtract(t).IsEmpty()
+}
+
+func (s Space) Contains(p Packet) bool {
+	var point = p.Box()
+	for _, b := range s {
+		if b.Contains(point) {
+			return true
+		}
+	}
+	return false
+}
+
+// Packet is a single IPv4 packet header.
+type Packet struct {
+	Src     net.IP
+	Dst     net.IP
+	Proto   int
+	SrcPort int
+	DstPort int
+}
+
+func newPacket(src string, dst string, proto string, sport int, dport int) (Packet, error) {
+	var ret = Packet{Src: net.ParseIP(src).To4(), Dst: net.ParseIP(dst).To4(), SrcPort: sport, DstPort: dport}
+	if ret.Src == nil {
+		return ret, errors.New("invalid source address: " + src)
+	}
+	if ret.Dst == nil {
+		return ret, errors.New("invalid destination address: " + dst)
+	}
+
+	var ok bool
+	ret.Proto, ok = protocolNumber(proto)
+	if !ok {
+		return ret, errors.New("invalid protocol: " + proto)
+	}
+	if sport < 0 || sport > 65535 || dport < 0 || dport > 65535 {
+		return ret, errors.New("invalid port")
+	}
+	return ret, nil
+}
+
+func (p Packet) Box() Box {
+	return Box{
+		Src:     Range{ipToUint32(p.Src), ipToUint32(p.Src)},
+		Dst:     Range{ipToUint32(p.Dst), ipToUint32(p.Dst)},
+		Proto:   Range{uint32(p.Proto), uint32(p.Proto)},
+		SrcPort: Range{uint32(p.SrcPort), uint32(p.SrcPort)},
+		DstPort: Range{uint32(p.DstPort), uint32(p.DstPort)},
+	}
+}
+
+func (p Packet) String() string {
+	switch p.Proto {
+	case protoTCP, protoUDP:
+		return fmt.Sprintf("%s %s:%d -> %s:%d", protocolName(p.Proto), p.Src, p.SrcPort, p.Dst, p.DstPort)
+	case protoICMP:
+		return fmt.Sprintf("icmp type %d %s -> %s", p.DstPort, p.Src, p.Dst)
+	default:
+		return fmt.Sprintf("%s %s -> %s", protocolName(p.Proto), p.Src, p.Dst)
+	}
+}
+
+func protocolName(proto int) string {
+	switch proto {
+	case protoICMP:
+		return "icmp"
+	case protoTCP:
+		return "tcp"
+	case protoUDP:
+		return "udp"
+	default:
+		return "proto " + strconv.Itoa(proto)
+	}
+}
+
+// protocolNumber converts a ScreenOS/RouterOS protocol name or number.
+func protocolNumber(proto string) (int, bool) {
+	switch proto {
+	case "icmp":
+		return protoICMP, true
+	case "tcp":
+		return protoTCP, true
+	case "udp":
+		return protoUDP, true
+	case "gre":
+		return 47, true
+	case "ipsec-esp":
+		return 50, true
+	case "ipsec-ah":
+		return 51, true
+	}
+	n, err := strconv.Atoi(proto)
+	if err != nil || n < 0 || n > 255 {
+		return 0, false
+	}
+	return n, true
+}
+
+// ipv4Range returns the range of addresses of an IPv4 network. IPv6 networks are not supported.
+func ipv4Range(n *net.IPNet) (Range, bool) {
+	ip := n.IP.To4()
+	if ip == nil {
+		return Range{}, false
+	}
+	mask := n.Mask
+	if len(mask) == net.IPv6len {
+		mask = mask[12:]
+	}
+	lo := binary.BigEndian.Uint32(ip) & binary.BigEndian.Uint32(mask)
+	return Range{lo, lo | ^binary.BigEndian.Uint32(mask)}, true
+}
+
+func ipToUint32(ip net.IP) uint32 {
+	if ip4 := ip.To4(); ip4 != nil {
+		return binary.BigEndian.Uint32(ip4)
+	}
+	return 0
+}
+
+func uint32ToIP(n uint32) net.IP {
+	var ret = make(net.IP, net.IPv4len)
+	binary.BigEndian.PutUint32(ret, n)
+	return ret
+}
+
+// addressRanges resolves the given address book entries of a zone.
+func addressRanges(objects Objects, zone string, names []string) []Range {
+	var ret []Range
+	for _, name := range names {
+		_, lookup := objects.Lookup(zone, name)
+		for _, n := range lookup {
+			if r, ok := ipv4Range(n); ok {
+				ret = append(ret, r)
+			}
+		}
+	}
+	return ret
+}
+
+// serviceBox returns the protocol and port part of a service entry. An empty protocol matches any protocol.
+func serviceBox(s Service) (Box, bool) {
+	var ret = FullBox
+	if s.Protocol == "" {
+		return ret, true
+	}
+
+	proto, ok := protocolNumber(s.Protocol)
+	if !ok {
+		return Box{}, false
+	}
+	ret.Proto = Range{uint32(proto), uint32(proto)}
+
+	switch proto {
+	case protoTCP, protoUDP:
+		ret.SrcPort = Range{uint32(s.SrcPortStart), uint32(s.SrcPortEnd)}
+		ret.DstPort = Range{uint32(s.DstPortStart), uint32(s.DstPortEnd)}
+	case protoICMP:
+		// Type 0 means any ICMP type (e.g. ICMP-ANY)
+		if s.IcmpType != 0 {
+			ret.DstPort = Range{uint32(s.IcmpType), uint32(s.IcmpType)}
+		}
+	}
+	return ret, true
+}
+
+// serviceSpace returns the protocol and port part of the given services. Unknown service names are returned as the
+// second value.
+func serviceSpace(services Services, names []string) (Space, []string) {
+	var ret Space
+	var unknown []string
+	for _, name := range names {
+		list, ok := services[name]
+		if !ok {
+			unknown = append(unknown, name)
+			continue
+		}
+		for _, s := range list {
+			if b, ok := serviceBox(s); ok {
+				ret = append(ret, b)
+			}
+		}
+	}
+	return ret, unknown
+}
+
+// policySpace returns the packets matched by the policy, regardless of its action. Disabled policies match nothing.
+func policySpace(p Policy, objects Objects, services Services) Space {
+	if p.Disabled {
+		return nil
+	}
+
+	var ret Space
+	svc, _ := serviceSpace(services, p.Services)
+	for _, src := range addressRanges(objects, p.From, p.Sources) {
+		for _, dst := range addressRanges(objects, p.To, p.Destinations) {
+			for _, b := range svc {
+				b.Src = src
+				b.Dst = dst
+				ret = append(ret, b)
+			}
+		}
+	}
+	return ret
+}
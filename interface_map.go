@@ -0,0 +1,46 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// InterfaceMap maps ScreenOS interface names (e.g. "ethernet0/1") to RouterOS ones (e.g. "ether2").
+type InterfaceMap map[string]string
+
+// parseInterfaceMap reads one "screenos-name routeros-name" pair per line. Empty lines and lines starting with "#" are
+// ignored.
+func parseInterfaceMap(reader io.Reader) InterfaceMap {
+	var ret = make(InterfaceMap)
+
+	var scanner = bufio.NewScanner(reader)
+	for scanner.Scan() {
+		var line = strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			panic(line)
+		}
+		ret[fields[0]] = fields[1]
+	}
+	return ret
+}
+
+// Name returns the RouterOS name for the given ScreenOS interface. Subinterfaces (e.g. "ethernet0/1.10") which are not
+// mapped explicitly inherit the mapping of their parent interface. If no mapping is found, the ScreenOS name is
+// returned and the second return value is false.
+func (m InterfaceMap) Name(name string) (string, bool) {
+	if n, ok := m[name]; ok {
+		return n, true
+	}
+	if idx := strings.LastIndex(name, "."); idx > 0 {
+		if n, ok := m[name[:idx]]; ok {
+			return n + name[idx:], true
+		}
+	}
+	return name, false
+}
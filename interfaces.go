@@ -0,0 +1,88 @@
+package main
+
+import (
+	"net"
+	"sort"
+	"strings"
+)
+
+const (
+	InterfaceModeRoute = "route"
+	InterfaceModeNAT   = "nat"
+)
+
+type Interface struct {
+	Name      string
+	Zone      string
+	Tag       int
+	Addresses []*net.IPNet
+	Mode      string
+	Manage    []string
+
+	// DHCP
+	DHCPServer       *DHCPServer
+	DHCPRelayServers []string
+	DHCPRelay        bool
+}
+
+type DHCPRange struct {
+	Start net.IP
+	End   net.IP
+}
+
+type DHCPReservation struct {
+	IP  net.IP
+	MAC string
+}
+
+type DHCPServer struct {
+	Enabled      bool
+	Ranges       []DHCPRange
+	Reservations []DHCPReservation
+	Gateway      net.IP
+	Netmask      net.IPMask
+	DNS          []net.IP
+	Domain       string
+	// Lease is the lease time in minutes
+	Lease int
+}
+
+type Interfaces map[string]*Interface
+
+// Get returns the interface with the given name, creating it if it doesn't exist yet.
+func (i Interfaces) Get(name string) *Interface {
+	if _, ok := i[name]; !ok {
+		i[name] = &Interface{Name: name}
+	}
+	return i[name]
+}
+
+func (i Interfaces) Names() []string {
+	var ret = make([]string, 0, len(i))
+	for name := range i {
+		ret = append(ret, name)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// DHCP returns the DHCP server settings of the interface, creating them if they don't exist yet.
+func (i *Interface) DHCP() *DHCPServer {
+	if i.DHCPServer == nil {
+		i.DHCPServer = &DHCPServer{}
+	}
+	return i.DHCPServer
+}
+
+// Parent returns the physical interface of a tagged subinterface (e.g. "ethernet0/1" for "ethernet0/1.10").
+func (i *Interface) Parent() string {
+	if idx := strings.LastIndex(i.Name, "."); idx > 0 {
+		return i.Name[:idx]
+	}
+	return i.Name
+}
+
+// networkOf returns the connected network of the given interface address.
+func networkOf(address *net.IPNet) *net.IPNet {
+	return &net.IPNet{IP: address.IP.Mask(address.Mask), Mask: address.Mask}
+}
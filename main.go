@@ -1,20 +1,442 @@
 package main
 
 import (
+	"bytes"
+	"flag"
 	"fmt"
+	"io"
 	"os"
+	"strings"
 )
 
 func main() {
-	policies, objects, services := parse(os.Stdin)
+	var zone = flag.String("zone", "Clients", "convert only policies from or to this zone (empty for all zones)")
+	var aggregate = flag.Bool("aggregate", false, "collapse address lists to the minimal set of CIDRs")
+	var compress = flag.Bool("compress", false, "merge the addresses and the ports of each policy in as few rules as possible")
+	var tag = flag.String("tag", "", "tag the comment of every generated item with this prefix (in square brackets)")
+	var tagCleanup = flag.Bool("tag-cleanup", false, "start the script removing the items tagged by a previous conversion")
+	var format = flag.String("format", FormatRsc, "output of convert: \"rsc\" for the RouterOS script, \"json\" or \"yaml\" for the parsed configuration")
+	var inputFormat = flag.String("input-format", FormatAuto, "format of the input: \"screenos\", \"json\" or \"yaml\" for an exported configuration, \"auto\" to detect it")
+	var configFile = flag.String("config", "", "read the configuration from this file instead of the standard input")
+	var provenance = flag.Bool("provenance", false, "append the configuration lines of the policy to the comment of each filter rule")
+	var interfaceMapFile = flag.String("interface-map", "", "file with one \"screenos-interface routeros-interface\" pair per line")
+	flag.Usage = func() {
+		_, _ = fmt.Fprintln(flag.CommandLine.Output(), "Usage: "+os.Args[0]+" [flags] [command] < netscreen.cfg|model.json|model.yaml")
+		_, _ = fmt.Fprintln(flag.CommandLine.Output(), "\nCommands:")
+		_, _ = fmt.Fprintln(flag.CommandLine.Output(), "  convert\tconvert the configuration to a RouterOS script (default)")
+		_, _ = fmt.Fprintln(flag.CommandLine.Output(), "  analyze\treport shadowed, redundant and conflicting policies")
+		_, _ = fmt.Fprintln(flag.CommandLine.Output(), "  unused\treport unused objects and services, empty groups and undefined group members")
+		_, _ = fmt.Fprintln(flag.CommandLine.Output(), "  diff old.cfg new.cfg\treport the policy, object and service changes between two configurations (see \"diff -h\")")
+		_, _ = fmt.Fprintln(flag.CommandLine.Output(), "  graph\tdraw the policies between zones as a Graphviz (or Mermaid with -mermaid) graph")
+		_, _ = fmt.Fprintln(flag.CommandLine.Output(), "  matrix\twrite the zone pair access matrix as CSV (see \"matrix -h\")")
+		_, _ = fmt.Fprintln(flag.CommandLine.Output(), "  trace\tevaluate a packet against the policies (see \"trace -h\")")
+		_, _ = fmt.Fprintln(flag.CommandLine.Output(), "  report\twrite a Markdown (or HTML with -html) report of the conversion of each policy")
+		_, _ = fmt.Fprintln(flag.CommandLine.Output(), "  push\tapply the converted configuration through the RouterOS API (see \"push -h\")")
+		_, _ = fmt.Fprintln(flag.CommandLine.Output(), "  sync export.rsc\tconvert only the address list and filter rule changes needed by a router export")
+		_, _ = fmt.Fprintln(flag.CommandLine.Output(), "  test\trun packet tests from a CSV file against the policies and the RouterOS rules (see \"test -h\")")
+		_, _ = fmt.Fprintln(flag.CommandLine.Output(), "  verify\tcompare the policies with the generated RouterOS filter rules (see \"verify -h\")")
+		_, _ = fmt.Fprintln(flag.CommandLine.Output(), "\nFlags:")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	var opts = MikrotikOptions{Aggregate: *aggregate, Compress: *compress, Tag: *tag, TagCleanup: *tagCleanup,
+		Provenance: *provenance, Zone: *zone}
+
+	var input io.Reader = os.Stdin
+	var inputName = "stdin"
+	if *configFile != "" {
+		fp, err := os.Open(*configFile)
+		if err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(2)
+		}
+		defer func() { _ = fp.Close() }()
+		input = fp
+		inputName = *configFile
+	}
+
+	var readConfig = func(reader io.Reader, file string) Config {
+		cfg, err := loadConfig(reader, file, *inputFormat)
+		if err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(2)
+		}
+		return cfg
+	}
+
+	var command = "convert"
+	if flag.NArg() > 0 {
+		command = flag.Arg(0)
+	}
+
+	switch command {
+	case "convert":
+		ifmap, err := readInterfaceMap(*interfaceMapFile)
+		if err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+
+		cfg := readConfig(input, inputName)
+		if *format != FormatRsc {
+			for _, section := range omittedSections(cfg) {
+				_, _ = fmt.Fprintln(os.Stderr, "model: "+section+" not exported")
+			}
+			out, err := marshalModel(exportModel(cfg), *format)
+			if err != nil {
+				_, _ = fmt.Fprintln(os.Stderr, err.Error())
+				os.Exit(2)
+			}
+			//nolint:forbidigo
+			fmt.Print(string(out))
+			return
+		}
+
+		//nolint:forbidigo
+		fmt.Println(convertConfig(cfg, *zone, ifmap, opts))
+	case "analyze":
+		cfg := readConfig(input, inputName)
+		for _, f := range analyzePolicies(cfg.Policies, cfg.Objects, cfg.Services) {
+			//nolint:forbidigo
+			fmt.Println(f.String())
+		}
+	case "unused":
+		cfg := readConfig(input, inputName)
+		for _, line := range findUnused(cfg) {
+			//nolint:forbidigo
+			fmt.Println(line)
+		}
+	case "graph":
+		var flags = flag.NewFlagSet("graph", flag.ExitOnError)
+		var mermaid = flags.Bool("mermaid", false, "write a Mermaid flowchart instead of a Graphviz graph")
+		_ = flags.Parse(flag.Args()[1:])
+
+		cfg := readConfig(input, inputName)
+		edges := zoneEdges(cfg.Policies)
+		if *mermaid {
+			//nolint:forbidigo
+			fmt.Print(graphMermaid(cfg.Zones(), edges))
+		} else {
+			//nolint:forbidigo
+			fmt.Print(graphDOT(cfg.Zones(), edges))
+		}
+	case "matrix":
+		var flags = flag.NewFlagSet("matrix", flag.ExitOnError)
+		var tuples = flags.Bool("tuples", false, "list the expanded (source, destination, service, action) tuples instead")
+		var xlsxFile = flags.String("xlsx", "", "write both the matrix and the tuples to this XLSX file instead")
+		_ = flags.Parse(flag.Args()[1:])
+
+		cfg := readConfig(input, inputName)
+		matrix := accessMatrix(cfg.Policies, cfg.Zones())
+		expanded := accessTuples(cfg.Policies, cfg.Objects, cfg.Services)
+		switch {
+		case *xlsxFile != "":
+			fp, err := os.Create(*xlsxFile)
+			if err != nil {
+				_, _ = fmt.Fprintln(os.Stderr, err.Error())
+				os.Exit(1)
+			}
+			err = writeXLSX(fp, []XLSXSheet{{Name: "Matrix", Rows: matrix}, {Name: "Tuples", Rows: expanded}})
+			if closeErr := fp.Close(); err == nil {
+				err = closeErr
+			}
+			if err != nil {
+				_, _ = fmt.Fprintln(os.Stderr, err.Error())
+				os.Exit(1)
+			}
+		case *tuples:
+			//nolint:forbidigo
+			fmt.Print(formatCSV(expanded))
+		default:
+			//nolint:forbidigo
+			fmt.Print(formatCSV(matrix))
+		}
+	case "trace":
+		var flags = flag.NewFlagSet("trace", flag.ExitOnError)
+		var from = flags.String("from", "", "source zone")
+		var to = flags.String("to", "", "destination zone")
+		var src = flags.String("src", "", "source IP address")
+		var dst = flags.String("dst", "", "destination IP address")
+		var proto = flags.String("proto", "tcp", "protocol name or number")
+		var sport = flags.Int("sport", defaultSourcePort, "source port")
+		var dport = flags.Int("dport", 0, "destination port (ICMP type for icmp)")
+		_ = flags.Parse(flag.Args()[1:])
+
+		pkt, err := newPacket(*src, *dst, *proto, *sport, *dport)
+		if err != nil || *from == "" || *to == "" {
+			if err != nil {
+				_, _ = fmt.Fprintln(os.Stderr, err.Error())
+			}
+			flags.Usage()
+			os.Exit(2)
+		}
+
+		cfg := readConfig(input, inputName)
+		//nolint:forbidigo
+		fmt.Print(tracePacket(cfg.Policies, cfg.Objects, cfg.Services, *from, *to, pkt).String())
+	case "verify":
+		var flags = flag.NewFlagSet("verify", flag.ExitOnError)
+		var rscFile = flags.String("rsc", "", "RouterOS script to verify, instead of converting the configuration")
+		_ = flags.Parse(flag.Args()[1:])
+
+		cfg := readConfig(input, inputName)
+		firewall, err := mikrotikFirewallFor(cfg, *zone, opts, *rscFile)
+		if err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+
+		differences, err := verifyConversion(cfg.Policies, cfg.Objects, cfg.Services, firewall, cfg.Zones(), *zone)
+		if err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		for _, d := range differences {
+			//nolint:forbidigo
+			fmt.Println(d.String())
+		}
+		if len(differences) > 0 {
+			os.Exit(1)
+		}
+	case "test":
+		var flags = flag.NewFlagSet("test", flag.ExitOnError)
+		var rscFile = flags.String("rsc", "", "RouterOS script to test, instead of converting the configuration")
+		flags.Usage = func() {
+			_, _ = fmt.Fprintln(flags.Output(), "Usage: "+os.Args[0]+" [flags] test [-rsc mikrotik.rsc] tests.csv < netscreen.cfg")
+			_, _ = fmt.Fprintln(flags.Output(), "\nEach line of tests.csv is: from_zone,to_zone,src,dst,proto,dport,expected_action")
+			flags.PrintDefaults()
+		}
+		_ = flags.Parse(flag.Args()[1:])
+		if flags.NArg() != 1 {
+			flags.Usage()
+			os.Exit(2)
+		}
+
+		fp, err := os.Open(flags.Arg(0))
+		if err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(2)
+		}
+		tests, err := parsePacketTests(fp)
+		_ = fp.Close()
+		if err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, flags.Arg(0)+": "+err.Error())
+			os.Exit(2)
+		}
+
+		cfg := readConfig(input, inputName)
+		firewall, err := mikrotikFirewallFor(cfg, *zone, opts, *rscFile)
+		if err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(2)
+		}
+
+		results, err := runPacketTests(tests, cfg.Policies, cfg.Objects, cfg.Services, firewall)
+		if err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(2)
+		}
+		//nolint:forbidigo
+		fmt.Print(formatPacketTestResults(results))
+		for _, r := range results {
+			if !r.Passed() {
+				os.Exit(1)
+			}
+		}
+	case "sync":
+		if flag.NArg() != 2 {
+			_, _ = fmt.Fprintln(os.Stderr, "Usage: "+os.Args[0]+" [flags] sync export.rsc < netscreen.cfg")
+			os.Exit(2)
+		}
+
+		fp, err := os.Open(flag.Arg(1))
+		if err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		current := parseRsc(fp)
+		_ = fp.Close()
+
+		cfg := readConfig(input, inputName)
+		rsc := tagMikrotik(buildMikrotik(filterPolicies(cfg.Policies, *zone), cfg.Objects, cfg.Services, opts), opts.Tag, false)
+		desired := parseRsc(strings.NewReader(rsc))
+		//nolint:forbidigo
+		fmt.Print(syncMikrotik(current, desired))
+	case "report":
+		var flags = flag.NewFlagSet("report", flag.ExitOnError)
+		var asHTML = flags.Bool("html", false, "write an HTML page instead of Markdown")
+		_ = flags.Parse(flag.Args()[1:])
+
+		text, err := io.ReadAll(input)
+		if err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		cfg := readConfig(bytes.NewReader(text), inputName)
+		report := buildReport(cfg, string(text), *zone, opts)
+		if *asHTML {
+			//nolint:forbidigo
+			fmt.Print(report.HTML())
+		} else {
+			//nolint:forbidigo
+			fmt.Print(report.Markdown())
+		}
+	case "diff":
+		var flags = flag.NewFlagSet("diff", flag.ExitOnError)
+		var routeros = flags.Bool("routeros", false, "print the RouterOS address list and filter rule changes instead, like sync")
+		flags.Usage = func() {
+			_, _ = fmt.Fprintln(flags.Output(), "Usage: "+os.Args[0]+" [flags] diff [-routeros] old.cfg new.cfg")
+			flags.PrintDefaults()
+		}
+		_ = flags.Parse(flag.Args()[1:])
+		if flags.NArg() != 2 {
+			flags.Usage()
+			os.Exit(2)
+		}
 
-	var filteredPolicies []Policy
+		var configs [2]Config
+		for idx := range configs {
+			fp, err := os.Open(flags.Arg(idx))
+			if err != nil {
+				_, _ = fmt.Fprintln(os.Stderr, err.Error())
+				os.Exit(2)
+			}
+			configs[idx] = readConfig(fp, flags.Arg(idx))
+			_ = fp.Close()
+		}
+
+		if *routeros {
+			//nolint:forbidigo
+			fmt.Print(diffRouterOS(configs[0], configs[1], *zone, opts))
+			return
+		}
+
+		differences := diffConfigs(configs[0], configs[1])
+		for _, line := range differences {
+			//nolint:forbidigo
+			fmt.Println(line)
+		}
+		if len(differences) > 0 {
+			os.Exit(1)
+		}
+	case "push":
+		var flags = flag.NewFlagSet("push", flag.ExitOnError)
+		var address = flags.String("address", "", "router address, with the port if not the default API port")
+		var user = flags.String("user", "admin", "router user")
+		var password = flags.String("password", os.Getenv("ROUTEROS_PASSWORD"), "router password (default $ROUTEROS_PASSWORD)")
+		var useTLS = flags.Bool("tls", false, "use the API over TLS (port 8729)")
+		var dryRun = flags.Bool("dry-run", false, "print the API sentences instead of applying them")
+		var rscFile = flags.String("rsc", "", "RouterOS script to push (e.g. the output of sync), instead of converting the configuration")
+		flags.Usage = func() {
+			_, _ = fmt.Fprintln(flags.Output(), "Usage: "+os.Args[0]+" [flags] push [-address router] [-dry-run] [-rsc mikrotik.rsc] < netscreen.cfg")
+			flags.PrintDefaults()
+		}
+		_ = flags.Parse(flag.Args()[1:])
+		if *address == "" && !*dryRun {
+			flags.Usage()
+			os.Exit(2)
+		}
+
+		var rsc string
+		if *rscFile != "" {
+			content, err := os.ReadFile(*rscFile)
+			if err != nil {
+				_, _ = fmt.Fprintln(os.Stderr, err.Error())
+				os.Exit(1)
+			}
+			rsc = string(content)
+		} else {
+			ifmap, err := readInterfaceMap(*interfaceMapFile)
+			if err != nil {
+				_, _ = fmt.Fprintln(os.Stderr, err.Error())
+				os.Exit(1)
+			}
+			rsc = convertConfig(readConfig(input, inputName), *zone, ifmap, opts)
+		}
+
+		commands, err := apiCommands(parseRsc(strings.NewReader(rsc)))
+		if err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+		if *dryRun {
+			for _, c := range commands {
+				//nolint:forbidigo
+				fmt.Print(c.String())
+			}
+			return
+		}
+
+		client, err := dialAPI(*address, *useTLS)
+		if err == nil {
+			err = client.Login(*user, *password)
+			if err == nil {
+				err = pushMikrotik(client, commands, os.Stderr)
+			}
+			_ = client.Close()
+		}
+		if err != nil {
+			_, _ = fmt.Fprintln(os.Stderr, err.Error())
+			os.Exit(1)
+		}
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+}
+
+// readInterfaceMap reads the interface map file, if any.
+func readInterfaceMap(file string) (InterfaceMap, error) {
+	if file == "" {
+		return make(InterfaceMap), nil
+	}
+	fp, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = fp.Close() }()
+	return parseInterfaceMap(fp), nil
+}
+
+// convertConfig converts the whole configuration to a RouterOS script. The NAT section follows the IPsec one, as
+// IPsec policies need their NAT bypass ahead of the masquerade rules.
+func convertConfig(cfg Config, zone string, ifmap InterfaceMap, opts MikrotikOptions) string {
+	vpn, ipsec := buildMikrotikVPN(cfg.VPN, cfg.Policies, cfg.Objects, cfg.Interfaces, ifmap)
+	return tagMikrotik(buildMikrotikInterfaces(cfg.Interfaces, cfg.Zones(), ifmap)+
+		buildMikrotikRoutes(cfg.VRouters, cfg.Routes, ifmap)+
+		buildMikrotikDHCP(cfg.Interfaces, ifmap)+
+		vpn+
+		buildMikrotikNAT(cfg.Interfaces, cfg.Routes, ipsec)+
+		buildMikrotikAdmin(cfg.Admin)+
+		buildMikrotikScreen(cfg.Screens, cfg.Interfaces)+
+		buildMikrotikInput(cfg.Interfaces, cfg.VPN, cfg.Admin, ifmap)+
+		buildMikrotik(filterPolicies(cfg.Policies, zone), cfg.Objects, cfg.Services, opts), opts.Tag, opts.TagCleanup)
+}
+
+// filterPolicies returns the policies from or to the zone, or all of them if the zone is empty.
+func filterPolicies(policies []Policy, zone string) []Policy {
+	var ret []Policy
 	for _, p := range policies {
-		if p.From == "Clients" || p.To == "Clients" {
-			filteredPolicies = append(filteredPolicies, p)
+		if zone == "" || p.From == zone || p.To == zone {
+			ret = append(ret, p)
 		}
 	}
+	return ret
+}
+
+// mikrotikFirewallFor returns the model of the given RouterOS script, or of the filter rules converted from the
+// configuration if no script is given.
+func mikrotikFirewallFor(cfg Config, zone string, opts MikrotikOptions, rscFile string) (MikrotikFirewall, error) {
+	if rscFile == "" {
+		rsc := buildMikrotik(filterPolicies(cfg.Policies, zone), cfg.Objects, cfg.Services, opts)
+		return newMikrotikFirewall(parseRsc(strings.NewReader(rsc))), nil
+	}
 
-	//nolint:forbidigo
-	fmt.Println(buildMikrotik(filteredPolicies, objects, services))
+	fp, err := os.Open(rscFile)
+	if err != nil {
+		return MikrotikFirewall{}, err
+	}
+	defer func() { _ = fp.Close() }()
+	return newMikrotikFirewall(parseRsc(fp)), nil
 }
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+var matrixHeader = []string{"from_zone", "to_zone", "policies", "permitted_services", "any_to_any", "default_action"}
+var tuplesHeader = []string{"policy", "from_zone", "to_zone", "src_object", "src_address", "dst_object", "dst_address",
+	"service", "service_entries", "action"}
+
+// accessMatrix returns a row for each pair of distinct zones (Global excluded): the number of enabled policies of the
+// pair, the services of its permitting policies, whether a policy matches any source to any destination, and the
+// action of the traffic not matched by a more specific policy. That's the action of the first any to any policy with
+// the ANY service of the pair, or else of the global policies, or else the ScreenOS default deny.
+func accessMatrix(policies []Policy, zones []string) [][]string {
+	var globalDefault = ActionDeny
+	for _, p := range policies {
+		if !p.Disabled && p.From == ZoneGlobal && p.To == ZoneGlobal && isAnyToAny(p) && containsString(p.Services, "ANY") {
+			globalDefault = p.Action
+			break
+		}
+	}
+
+	var ret = [][]string{matrixHeader}
+	for _, from := range zones {
+		for _, to := range zones {
+			if from == to || from == ZoneGlobal || to == ZoneGlobal {
+				continue
+			}
+
+			var count = 0
+			var services []string
+			var anyToAny = "no"
+			var defaultAction = ""
+			for _, p := range policies {
+				if p.Disabled || p.From != from || p.To != to {
+					continue
+				}
+				count++
+				if p.Permits() {
+					for _, s := range p.Services {
+						services = appendUnique(services, s)
+					}
+				}
+				if isAnyToAny(p) {
+					anyToAny = "yes"
+					if defaultAction == "" && containsString(p.Services, "ANY") {
+						defaultAction = p.Action
+					}
+				}
+			}
+			if defaultAction == "" {
+				defaultAction = globalDefault
+			}
+			sort.Strings(services)
+			ret = append(ret, []string{from, to, fmt.Sprint(count), strings.Join(services, " "), anyToAny, defaultAction})
+		}
+	}
+	return ret
+}
+
+func isAnyToAny(p Policy) bool {
+	return len(p.Sources) == 1 && strings.ToLower(p.Sources[0]) == "any" &&
+		len(p.Destinations) == 1 && strings.ToLower(p.Destinations[0]) == "any"
+}
+
+// accessTuples expands each enabled policy to a row for every source address, destination address and service, with
+// the object each address comes from. Addresses which can't be resolved are listed as "not found".
+func accessTuples(policies []Policy, objects Objects, services Services) [][]string {
+	var ret = [][]string{tuplesHeader}
+	for _, p := range policies {
+		if p.Disabled {
+			continue
+		}
+
+		srcNames, srcAddresses := tupleAddresses(objects, p.From, p.Sources)
+		dstNames, dstAddresses := tupleAddresses(objects, p.To, p.Destinations)
+		for _, service := range p.Services {
+			entries := "any"
+			if service != "ANY" {
+				entries = serviceListString(services[service])
+			}
+			for idx := range srcAddresses {
+				for jdx := range dstAddresses {
+					ret = append(ret, []string{fmt.Sprint(p.ID), p.From, p.To, srcNames[idx], srcAddresses[idx],
+						dstNames[jdx], dstAddresses[jdx], service, entries, p.Action})
+				}
+			}
+		}
+	}
+	return ret
+}
+
+// tupleAddresses resolves the addresses of a policy with Objects.Lookup, returning the object of each address.
+func tupleAddresses(objects Objects, zone string, names []string) ([]string, []string) {
+	var retNames, retAddresses []string
+	for _, name := range names {
+		objectNames, lookup := objects.Lookup(zone, name)
+		if len(lookup) == 0 {
+			retNames = append(retNames, name)
+			retAddresses = append(retAddresses, "not found")
+			continue
+		}
+		for idx, address := range lookup {
+			retNames = append(retNames, objectNames[idx])
+			retAddresses = append(retAddresses, address.String())
+		}
+	}
+	return retNames, retAddresses
+}
+
+// formatCSV formats the rows as CSV.
+func formatCSV(rows [][]string) string {
+	var ret strings.Builder
+	var w = csv.NewWriter(&ret)
+	_ = w.WriteAll(rows)
+	return ret.String()
+}
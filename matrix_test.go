@@ -0,0 +1,42 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAccessMatrixDefaultAction(t *testing.T) {
+	var anyAddress = []string{"Any"}
+	var pairPolicies = []Policy{
+		{ID: 1, From: "Trust", To: "DMZ", Sources: anyAddress, Destinations: anyAddress, Services: []string{"HTTP"}, Action: ActionPermit},
+		{ID: 2, From: "Trust", To: "DMZ", Sources: anyAddress, Destinations: anyAddress, Services: []string{"ANY"}, Action: ActionPermit,
+			Disabled: true},
+		{ID: 3, From: "Trust", To: "DMZ", Sources: anyAddress, Destinations: anyAddress, Services: []string{"ANY"}, Action: ActionReject},
+		{ID: 4, From: "Trust", To: "DMZ", Sources: anyAddress, Destinations: anyAddress, Services: []string{"ANY"}, Action: ActionPermit},
+		{ID: 5, From: "DMZ", To: "Trust", Sources: []string{"s1"}, Destinations: anyAddress, Services: []string{"ANY"},
+			Action: ActionPermit},
+	}
+	var globalPolicy = Policy{ID: 6, From: ZoneGlobal, To: ZoneGlobal, Sources: anyAddress, Destinations: anyAddress,
+		Services: []string{"ANY"}, Action: ActionPermit}
+
+	for _, tc := range []struct {
+		name     string
+		policies []Policy
+		want     [][]string
+	}{
+		{"implicit deny", pairPolicies, [][]string{
+			matrixHeader,
+			{"DMZ", "Trust", "1", "ANY", "no", ActionDeny},
+			{"Trust", "DMZ", "3", "ANY HTTP", "yes", ActionReject},
+		}},
+		{"global", append(pairPolicies[:len(pairPolicies):len(pairPolicies)], globalPolicy), [][]string{
+			matrixHeader,
+			{"DMZ", "Trust", "1", "ANY", "no", ActionPermit},
+			{"Trust", "DMZ", "3", "ANY HTTP", "yes", ActionReject},
+		}},
+	} {
+		if got := accessMatrix(tc.policies, []string{"DMZ", ZoneGlobal, "Trust"}); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,405 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// modelVersion is the version of the Model schema, to be increased on incompatible changes.
+const modelVersion = 1
+
+const (
+	FormatAuto     = "auto"
+	FormatScreenOS = "screenos"
+	FormatRsc      = "rsc"
+	FormatJSON     = "json"
+	FormatYAML     = "yaml"
+)
+
+// Model is the parsed configuration exported as JSON or YAML: the policies in evaluation order (the policies of each
+// zone pair in configuration order, then the global ones), the zones, the address book of each zone, the services and
+// the NAT objects.
+type Model struct {
+	Version   int                       `json:"version" yaml:"version"`
+	Zones     []string                  `json:"zones" yaml:"zones"`
+	Policies  []Policy                  `json:"policies" yaml:"policies"`
+	Addresses map[string][]ModelAddress `json:"addresses" yaml:"addresses"`
+	Services  []ModelService            `json:"services" yaml:"services"`
+	NAT       ModelNAT                  `json:"nat" yaml:"nat"`
+}
+
+// ModelAddress is an address object, or a group with its members and the addresses they resolve to.
+type ModelAddress struct {
+	Name     string   `json:"name" yaml:"name"`
+	Address  string   `json:"address,omitempty" yaml:"address,omitempty"`
+	Group    bool     `json:"group,omitempty" yaml:"group,omitempty"`
+	Members  []string `json:"members,omitempty" yaml:"members,omitempty"`
+	Resolved []string `json:"resolved,omitempty" yaml:"resolved,omitempty"`
+}
+
+// ModelService is a service with its entries. Predefined services are the ScreenOS built-in ones.
+type ModelService struct {
+	Name       string      `json:"name" yaml:"name"`
+	Predefined bool        `json:"predefined,omitempty" yaml:"predefined,omitempty"`
+	Entries    ServiceList `json:"entries" yaml:"entries"`
+}
+
+type ModelNAT struct {
+	MIPs []ModelMIP `json:"mips" yaml:"mips"`
+	VIPs []ModelVIP `json:"vips" yaml:"vips"`
+	DIPs []ModelDIP `json:"dips" yaml:"dips"`
+}
+
+type ModelMIP struct {
+	Interface string `json:"interface" yaml:"interface"`
+	Address   string `json:"address" yaml:"address"`
+	Host      string `json:"host" yaml:"host"`
+	Netmask   string `json:"netmask" yaml:"netmask"`
+	VRouter   string `json:"vrouter" yaml:"vrouter"`
+}
+
+type ModelVIP struct {
+	Interface string `json:"interface" yaml:"interface"`
+	Address   string `json:"address" yaml:"address"`
+	Port      int    `json:"port" yaml:"port"`
+	Service   string `json:"service" yaml:"service"`
+	Host      string `json:"host" yaml:"host"`
+}
+
+type ModelDIP struct {
+	Interface string `json:"interface" yaml:"interface"`
+	ID        int    `json:"id" yaml:"id"`
+	Start     string `json:"start" yaml:"start"`
+	End       string `json:"end" yaml:"end"`
+	FixPort   bool   `json:"fix_port,omitempty" yaml:"fix_port,omitempty"`
+}
+
+// exportModel converts a configuration to the exported model. The sections it omits are listed by omittedSections.
+func exportModel(cfg Config) Model {
+	var ret = Model{
+		Version:   modelVersion,
+		Zones:     cfg.Zones(),
+		Policies:  []Policy{},
+		Addresses: make(map[string][]ModelAddress),
+		Services:  []ModelService{},
+		NAT:       ModelNAT{MIPs: []ModelMIP{}, VIPs: []ModelVIP{}, DIPs: []ModelDIP{}},
+	}
+
+	for _, global := range []bool{false, true} {
+		for _, p := range cfg.Policies {
+			if (p.From == ZoneGlobal || p.To == ZoneGlobal) == global {
+				ret.Policies = append(ret.Policies, p)
+			}
+		}
+	}
+
+	for _, zone := range cfg.Objects.Zones() {
+		ret.Zones = appendUnique(ret.Zones, zone)
+		var book = []ModelAddress{}
+		for _, name := range cfg.Objects.Names(zone) {
+			obj := cfg.Objects[zone][name]
+			var address = ModelAddress{Name: name, Group: obj.Group, Members: obj.GroupMembers}
+			if obj.Group {
+				_, resolved := cfg.Objects.Lookup(zone, name)
+				for _, r := range resolved {
+					address.Resolved = append(address.Resolved, r.String())
+				}
+			} else if obj.Address != nil {
+				address.Address = obj.Address.String()
+			}
+			book = append(book, address)
+		}
+		ret.Addresses[zone] = book
+	}
+	sort.Strings(ret.Zones)
+
+	var defaults = defaultServices()
+	var names = make([]string, 0, len(cfg.Services))
+	for name := range cfg.Services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		_, predefined := defaults[name]
+		ret.Services = append(ret.Services, ModelService{Name: name, Predefined: predefined, Entries: cfg.Services[name]})
+	}
+
+	for _, m := range cfg.NAT.MIPs {
+		ret.NAT.MIPs = append(ret.NAT.MIPs, ModelMIP{Interface: m.Interface, Address: m.Address.String(),
+			Host: m.Host.String(), Netmask: net.IP(m.Netmask).String(), VRouter: m.VRouter})
+	}
+	for _, v := range cfg.NAT.VIPs {
+		ret.NAT.VIPs = append(ret.NAT.VIPs, ModelVIP{Interface: v.Interface, Address: v.Address, Port: v.Port,
+			Service: v.Service, Host: v.Host.String()})
+	}
+	for _, d := range cfg.NAT.DIPs {
+		ret.NAT.DIPs = append(ret.NAT.DIPs, ModelDIP{Interface: d.Interface, ID: d.ID, Start: d.Start.String(),
+			End: d.End.String(), FixPort: d.FixPort})
+	}
+	return ret
+}
+
+// omittedSections returns the parts of the configuration which aren't part of the model, and are lost in the export.
+func omittedSections(cfg Config) []string {
+	var ret []string
+	if len(cfg.Interfaces) > 0 {
+		ret = append(ret, "interfaces (with their zones, NAT mode, DHCP and management options)")
+	}
+	if len(cfg.VRouters) > 0 || len(cfg.Routes) > 0 {
+		ret = append(ret, "virtual routers and routes")
+	}
+	if len(cfg.VPN.P1Proposals) > 0 || len(cfg.VPN.P2Proposals) > 0 || len(cfg.VPN.Gateways) > 0 || len(cfg.VPN.VPNs) > 0 {
+		ret = append(ret, "IKE proposals, gateways and VPNs")
+	}
+	if len(cfg.Admin.Admins) > 0 || len(cfg.Admin.ManagerIPs) > 0 || cfg.Admin.AuthServer != "" ||
+		len(cfg.Admin.AuthServers) > 0 || len(cfg.Admin.Users) > 0 {
+		ret = append(ret, "admins, manager IPs, auth servers and users")
+	}
+	if len(cfg.Screens) > 0 {
+		ret = append(ret, "screen options")
+	}
+	return ret
+}
+
+// marshalModel encodes the model in the given format.
+func marshalModel(m Model, format string) ([]byte, error) {
+	switch format {
+	case FormatJSON:
+		ret, err := json.MarshalIndent(m, "", "  ")
+		return append(ret, '\n'), err
+	case FormatYAML:
+		var buf bytes.Buffer
+		var encoder = yaml.NewEncoder(&buf)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(m); err != nil {
+			return nil, err
+		}
+		err := encoder.Close()
+		return buf.Bytes(), err
+	}
+	return nil, errors.New("unknown format " + format)
+}
+
+// loadConfig reads a ScreenOS configuration, or a model in JSON or YAML. The auto format detects a JSON object, or a
+// YAML document starting with the version field, after any comment. The file name is recorded in the Provenance of the
+// parsed ScreenOS entities.
+func loadConfig(reader io.Reader, file string, format string) (Config, error) {
+	var r = bufio.NewReader(reader)
+	if format == FormatAuto {
+		format = FormatScreenOS
+		head, _ := r.Peek(4096)
+		for _, line := range strings.Split(string(head), "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			if strings.HasPrefix(line, "{") {
+				format = FormatJSON
+			} else if strings.HasPrefix(line, "---") || strings.HasPrefix(line, "version:") {
+				format = FormatYAML
+			}
+			break
+		}
+	}
+
+	var m Model
+	switch format {
+	case FormatScreenOS:
+		return parse(r, file), nil
+	case FormatJSON:
+		var decoder = json.NewDecoder(r)
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&m); err != nil {
+			return Config{}, err
+		}
+	case FormatYAML:
+		var decoder = yaml.NewDecoder(r)
+		decoder.KnownFields(true)
+		if err := decoder.Decode(&m); err != nil {
+			return Config{}, err
+		}
+	default:
+		return Config{}, errors.New("unknown input format " + format)
+	}
+	return importModel(m)
+}
+
+// importModel converts a model to a configuration, checking the policies with Policy.IsValid and the references to
+// addresses and services. The built-in services are always defined, and the resolved group addresses are ignored.
+func importModel(m Model) (Config, error) {
+	if m.Version != modelVersion {
+		return Config{}, fmt.Errorf("unsupported model version %d (expected %d)", m.Version, modelVersion)
+	}
+
+	var cfg = Config{
+		Objects:    make(Objects),
+		Services:   defaultServices(),
+		Interfaces: make(Interfaces),
+		VPN:        newVPNConfig(),
+		Admin:      newAdminConfig(),
+		Screens:    make(Screens),
+	}
+
+	for _, s := range m.Services {
+		for _, e := range s.Entries {
+			if e.SrcPortStart < 0 || e.SrcPortStart > e.SrcPortEnd || e.SrcPortEnd > 65535 ||
+				e.DstPortStart < 0 || e.DstPortStart > e.DstPortEnd || e.DstPortEnd > 65535 {
+				return Config{}, errors.New("service " + s.Name + ": invalid port range")
+			}
+		}
+		cfg.Services[s.Name] = s.Entries
+	}
+
+	for zone, book := range m.Addresses {
+		for _, a := range book {
+			if a.Group {
+				cfg.Objects.AddGroup(zone, a.Name)
+				for _, member := range a.Members {
+					cfg.Objects.AddToGroup(zone, a.Name, member)
+				}
+				continue
+			}
+			address, err := parseModelAddress(a.Address)
+			if err != nil {
+				return Config{}, fmt.Errorf("address %s/%s: %w", zone, a.Name, err)
+			}
+			cfg.Objects.Add(zone, a.Name, address)
+		}
+	}
+	for _, zone := range cfg.Objects.Zones() {
+		for _, name := range cfg.Objects.Names(zone) {
+			for _, member := range cfg.Objects[zone][name].GroupMembers {
+				if !cfg.Objects.defined(zone, member) {
+					return Config{}, fmt.Errorf("address %s/%s: undefined member %s", zone, name, member)
+				}
+			}
+		}
+		if cycle := groupCycle(cfg.Objects, zone); cycle != nil {
+			return Config{}, fmt.Errorf("address %s/%s: group cycle %s", zone, cycle[0], strings.Join(cycle, " -> "))
+		}
+	}
+
+	var ids = make(map[int]bool, len(m.Policies))
+	for _, p := range m.Policies {
+		if !p.IsValid() {
+			return Config{}, fmt.Errorf("policy %d: invalid policy %s", p.ID, p.String())
+		}
+		if ids[p.ID] {
+			return Config{}, fmt.Errorf("policy %d: duplicate ID", p.ID)
+		}
+		ids[p.ID] = true
+		for _, name := range p.Sources {
+			if !cfg.Objects.defined(p.From, name) {
+				return Config{}, fmt.Errorf("policy %d: undefined address %s/%s", p.ID, p.From, name)
+			}
+		}
+		for _, name := range p.Destinations {
+			if !cfg.Objects.defined(p.To, name) {
+				return Config{}, fmt.Errorf("policy %d: undefined address %s/%s", p.ID, p.To, name)
+			}
+		}
+		for _, name := range p.Services {
+			if _, ok := cfg.Services[name]; !ok {
+				return Config{}, fmt.Errorf("policy %d: undefined service %s", p.ID, name)
+			}
+		}
+		cfg.Policies = append(cfg.Policies, p)
+	}
+
+	for _, mip := range m.NAT.MIPs {
+		var netmask = net.ParseIP(mip.Netmask).To4()
+		if net.ParseIP(mip.Address) == nil || net.ParseIP(mip.Host) == nil || netmask == nil {
+			return Config{}, errors.New("mip " + mip.Address + ": invalid address")
+		}
+		cfg.NAT.MIPs = append(cfg.NAT.MIPs, MIP{Interface: mip.Interface, Address: net.ParseIP(mip.Address),
+			Host: net.ParseIP(mip.Host), Netmask: net.IPMask(netmask), VRouter: mip.VRouter})
+	}
+	for _, vip := range m.NAT.VIPs {
+		if (vip.Address != VIPInterfaceIP && net.ParseIP(vip.Address) == nil) || net.ParseIP(vip.Host) == nil {
+			return Config{}, errors.New("vip " + vip.Address + ": invalid address")
+		}
+		cfg.NAT.VIPs = append(cfg.NAT.VIPs, VIP{Interface: vip.Interface, Address: vip.Address, Port: vip.Port,
+			Service: vip.Service, Host: net.ParseIP(vip.Host)})
+	}
+	for _, dip := range m.NAT.DIPs {
+		if net.ParseIP(dip.Start) == nil || net.ParseIP(dip.End) == nil {
+			return Config{}, fmt.Errorf("dip %d: invalid address", dip.ID)
+		}
+		cfg.NAT.DIPs = append(cfg.NAT.DIPs, DIP{Interface: dip.Interface, ID: dip.ID, Start: net.ParseIP(dip.Start),
+			End: net.ParseIP(dip.End), FixPort: dip.FixPort})
+	}
+	return cfg, nil
+}
+
+// groupCycle returns a cycle of groups containing each other in the zone, like [A B A], or nil if there is none.
+// Objects.Lookup would recurse forever on it.
+func groupCycle(objects Objects, zone string) []string {
+	const (
+		visiting = 1
+		done     = 2
+	)
+	var state = make(map[string]int8)
+	var path []string
+
+	var visit func(name string) []string
+	visit = func(name string) []string {
+		obj, ok := objects[zone][name]
+		if !ok || !obj.Group || state[name] == done {
+			return nil
+		}
+		if state[name] == visiting {
+			for idx, n := range path {
+				if n == name {
+					return append(append([]string(nil), path[idx:]...), name)
+				}
+			}
+		}
+
+		state[name] = visiting
+		path = append(path, name)
+		for _, member := range obj.GroupMembers {
+			if cycle := visit(member); cycle != nil {
+				return cycle
+			}
+		}
+		path = path[:len(path)-1]
+		state[name] = done
+		return nil
+	}
+
+	for _, name := range objects.Names(zone) {
+		if cycle := visit(name); cycle != nil {
+			return cycle
+		}
+	}
+	return nil
+}
+
+// parseModelAddress parses an address in CIDR notation, or a host address.
+func parseModelAddress(address string) (*net.IPNet, error) {
+	if !strings.Contains(address, "/") {
+		ip := net.ParseIP(address)
+		if ip == nil {
+			return nil, errors.New("invalid address " + address)
+		}
+		if ip.To4() != nil {
+			return &net.IPNet{IP: ip, Mask: net.CIDRMask(32, 32)}, nil
+		}
+		return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}, nil
+	}
+
+	ip, network, err := net.ParseCIDR(address)
+	if err != nil {
+		return nil, err
+	}
+	return &net.IPNet{IP: ip, Mask: network.Mask}, nil
+}
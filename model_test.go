@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestOmittedSections(t *testing.T) {
+	for _, tc := range []struct {
+		config string
+		want   []string
+	}{
+		{"testdata/branch.cfg", []string{
+			"interfaces (with their zones, NAT mode, DHCP and management options)",
+			"virtual routers and routes",
+			"IKE proposals, gateways and VPNs",
+			"admins, manager IPs, auth servers and users",
+			"screen options",
+		}},
+		{"testdata/services.cfg", nil},
+		{"testdata/model.yaml", nil},
+	} {
+		fp, err := os.Open(tc.config)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cfg, err := loadConfig(fp, tc.config, FormatAuto)
+		_ = fp.Close()
+		if err != nil {
+			t.Fatalf("%s: %v", tc.config, err)
+		}
+		if got := omittedSections(cfg); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%s: got %q, want %q", tc.config, got, tc.want)
+		}
+	}
+}
+
+func TestImportModelReferences(t *testing.T) {
+	const model = `version: 1
+policies:
+  - id: 1
+    from: LAN
+    to: WAN
+    sources: [%s]
+    destinations: [%s]
+    services: [%s]
+    action: permit
+addresses:
+  LAN:
+    - name: Host
+      address: 10.0.0.1
+    - name: Hosts
+      group: true
+      members: [%s]
+`
+	for _, tc := range []struct {
+		src, dst, service, member string
+		want                      string
+	}{
+		{"Hosts", "any", "HTTP", "Host", ""},
+		{"Host", "MIP(203.0.113.5)", "HTTP", "Host", ""},
+		{"Nope", "Any", "HTTP", "Host", "policy 1: undefined address LAN/Nope"},
+		{"Host", "Host", "HTTP", "Host", "policy 1: undefined address WAN/Host"},
+		{"Host", "Any", "NOPE", "Host", "policy 1: undefined service NOPE"},
+		{"Host", "Any", "HTTP", "Missing", "address LAN/Hosts: undefined member Missing"},
+	} {
+		text := fmt.Sprintf(model, tc.src, tc.dst, tc.service, tc.member)
+		_, err := loadConfig(strings.NewReader(text), "model.yaml", FormatAuto)
+		var got string
+		if err != nil {
+			got = err.Error()
+		}
+		if got != tc.want {
+			t.Errorf("%v: got error %q, want %q", tc, got, tc.want)
+		}
+	}
+}
+
+func TestImportModelGroupCycle(t *testing.T) {
+	const model = `version: 1
+policies: []
+addresses:
+  LAN:
+    - name: A
+      group: true
+      members: [Host, B]
+    - name: B
+      group: true
+      members: [C]
+    - name: C
+      group: true
+      members: [%s]
+    - name: Host
+      address: 10.0.0.1
+`
+	for _, tc := range []struct {
+		member string
+		want   string
+	}{
+		{"Host", ""},
+		{"A", "address LAN/A: group cycle A -> B -> C -> A"},
+		{"C", "address LAN/C: group cycle C -> C"},
+	} {
+		_, err := loadConfig(strings.NewReader(fmt.Sprintf(model, tc.member)), "model.yaml", FormatAuto)
+		var got string
+		if err != nil {
+			got = err.Error()
+		}
+		if got != tc.want {
+			t.Errorf("member %s: got error %q, want %q", tc.member, got, tc.want)
+		}
+	}
+}
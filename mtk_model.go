@@ -0,0 +1,328 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	VerdictAccept = "accept"
+	VerdictDrop   = "drop"
+	VerdictReject = "reject"
+
+	maxJumpDepth = 32
+)
+
+// MikrotikRule is a rule of a RouterOS filter chain.
+type MikrotikRule struct {
+	Chain      string
+	Action     string
+	JumpTarget string
+	Comment    string
+	Line       int
+	Matchers   map[string]string
+}
+
+// Name identifies the rule in reports: its comment, or its chain and script line.
+func (r *MikrotikRule) Name() string {
+	if r.Comment != "" {
+		return "\"" + r.Comment + "\""
+	}
+	return fmt.Sprintf("chain %s rule at line %d", r.Chain, r.Line)
+}
+
+// MikrotikFirewall is a model of the address lists and filter chains of a RouterOS script.
+type MikrotikFirewall struct {
+	AddressLists map[string][]Range
+	Chains       map[string][]MikrotikRule
+}
+
+// ruleAttributes are the filter rule properties which don't affect the match.
+var ruleAttributes = map[string]bool{
+	"chain": true, "action": true, "jump-target": true, "comment": true, "log": true, "log-prefix": true,
+	"disabled": true, "place-before": true,
+}
+
+func newMikrotikFirewall(commands []RscCommand) MikrotikFirewall {
+	var ret = MikrotikFirewall{AddressLists: make(map[string][]Range), Chains: make(map[string][]MikrotikRule)}
+	for _, cmd := range commands {
+		if cmd.Verb != "add" || cmd.Args["disabled"] == "yes" {
+			continue
+		}
+
+		switch cmd.Menu {
+		case "/ip firewall address-list":
+			// IPv6 and DNS names can't be modeled
+			if r, err := parseAddressRange(cmd.Args["address"]); err == nil {
+				ret.AddressLists[cmd.Args["list"]] = append(ret.AddressLists[cmd.Args["list"]], r)
+			}
+		case "/ip firewall filter":
+			var rule = MikrotikRule{
+				Chain:      cmd.Args["chain"],
+				Action:     cmd.Args["action"],
+				JumpTarget: cmd.Args["jump-target"],
+				Comment:    cmd.Args["comment"],
+				Line:       cmd.Line,
+				Matchers:   make(map[string]string),
+			}
+			if rule.Action == "" {
+				rule.Action = VerdictAccept
+			}
+			for k, v := range cmd.Args {
+				if !ruleAttributes[k] {
+					rule.Matchers[k] = v
+				}
+			}
+			ret.Chains[rule.Chain] = append(ret.Chains[rule.Chain], rule)
+		}
+	}
+	return ret
+}
+
+// Evaluate returns the verdict of the forward chain for every new connection from a zone interface list to another.
+// Rate limits are assumed not exceeded, and port scan or connection limit rules not triggered.
+func (fw MikrotikFirewall) Evaluate(from string, to string) ([]Verdict, error) {
+	var verdicts []Verdict
+	rest, err := fw.evaluate("forward", Space{FullBox}, from, to, 0, &verdicts)
+	if err != nil {
+		return nil, err
+	}
+	for _, b := range rest {
+		verdicts = append(verdicts, Verdict{Box: b, Action: VerdictAccept, Source: "end of forward chain"})
+	}
+	return verdicts, nil
+}
+
+// Trace returns the verdict of the forward chain for a single packet.
+func (fw MikrotikFirewall) Trace(from string, to string, pkt Packet) (Verdict, error) {
+	var verdicts []Verdict
+	rest, err := fw.evaluate("forward", Space{pkt.Box()}, from, to, 0, &verdicts)
+	if err != nil {
+		return Verdict{}, err
+	}
+	if len(verdicts) == 0 && len(rest) > 0 {
+		return Verdict{Box: rest[0], Action: VerdictAccept, Source: "end of forward chain"}, nil
+	}
+	return verdicts[0], nil
+}
+
+// evaluate runs the packets through the chain, appending the final verdicts. Packets returned or falling off the end
+// of the chain are returned.
+func (fw MikrotikFirewall) evaluate(chain string, space Space, from string, to string, depth int, verdicts *[]Verdict) (Space, error) {
+	if depth > maxJumpDepth {
+		return nil, errors.New("too many nested jumps in chain " + chain)
+	}
+
+	var returned Space
+	for idx := range fw.Chains[chain] {
+		if space.IsEmpty() {
+			break
+		}
+
+		rule := &fw.Chains[chain][idx]
+		match, err := fw.ruleSpace(rule, from, to)
+		if err != nil {
+			return nil, err
+		}
+		var matched Space
+		matched, space = space.Split(match)
+		if matched.IsEmpty() {
+			continue
+		}
+
+		switch rule.Action {
+		case VerdictAccept, VerdictDrop, VerdictReject, "tarpit":
+			action := rule.Action
+			if action == "tarpit" {
+				action = VerdictDrop
+			}
+			for _, b := range matched {
+				*verdicts = append(*verdicts, Verdict{Box: b, Action: action, Source: rule.Name()})
+			}
+		case "jump":
+			back, err := fw.evaluate(rule.JumpTarget, matched, from, to, depth+1, verdicts)
+			if err != nil {
+				return nil, err
+			}
+			space = append(space, back...)
+		case "return":
+			returned = append(returned, matched...)
+		default:
+			// passthrough, log, add-*-to-address-list and so on don't stop the evaluation
+			space = append(space, matched...)
+		}
+	}
+	return append(returned, space...), nil
+}
+
+// ruleSpace returns the packets of the zone pair matched by a rule.
+func (fw MikrotikFirewall) ruleSpace(rule *MikrotikRule, from string, to string) (Space, error) {
+	var ret = Space{FullBox}
+
+	// Sorted for stable error messages
+	var keys = make([]string, 0, len(rule.Matchers))
+	for k := range rule.Matchers {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		value := rule.Matchers[k]
+		negated := strings.HasPrefix(value, "!")
+		value = strings.TrimPrefix(value, "!")
+
+		var ranges []Range
+		var dim int
+		var err error
+		switch k {
+		case "in-interface-list", "out-interface-list":
+			var zone = from
+			if k == "out-interface-list" {
+				zone = to
+			}
+			if (value == zone || value == "all") == negated {
+				return nil, nil
+			}
+			continue
+		case "connection-state":
+			if strings.Contains(value, "new") == negated {
+				return nil, nil
+			}
+			continue
+		case "tcp-flags", "limit", "dst-limit":
+			// Assume the first packet of a connection, under the rate limit
+			continue
+		case "psd", "connection-limit":
+			// Assume no port scan and no connection flood
+			return nil, nil
+		case "src-address", "dst-address":
+			dim = 0
+			if k == "dst-address" {
+				dim = 1
+			}
+			var r Range
+			r, err = parseAddressRange(value)
+			ranges = []Range{r}
+		case "src-address-list", "dst-address-list":
+			dim = 0
+			if k == "dst-address-list" {
+				dim = 1
+			}
+			ranges = fw.AddressLists[value]
+		case "protocol":
+			dim = 2
+			proto, ok := protocolNumber(value)
+			if !ok {
+				err = errors.New("unknown protocol " + value)
+			}
+			ranges = []Range{{uint32(proto), uint32(proto)}}
+		case "src-port", "dst-port":
+			dim = 3
+			if k == "dst-port" {
+				dim = 4
+			}
+			ranges, err = parsePortRanges(value)
+		case "icmp-options":
+			// Only the type is modeled, as the destination port
+			dim = 4
+			ranges, err = parsePortRanges(strings.SplitN(value, ":", 2)[0])
+		default:
+			err = errors.New("unsupported matcher " + k)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", rule.Name(), err)
+		}
+
+		if negated {
+			ranges = complementRanges(ranges, fullRange(dim))
+		}
+		ret = restrictSpace(ret, dim, ranges)
+	}
+	return ret, nil
+}
+
+// restrictSpace limits a dimension of every box of the space to the given ranges.
+func restrictSpace(space Space, dim int, ranges []Range) Space {
+	var ret Space
+	for _, b := range space {
+		for _, r := range ranges {
+			c := FullBox
+			*c.dims()[dim] = r
+			if inter, ok := b.Intersect(c); ok {
+				ret = append(ret, inter)
+			}
+		}
+	}
+	return ret
+}
+
+func fullRange(dim int) Range {
+	return *FullBox.dims()[dim]
+}
+
+// complementRanges returns the parts of full not covered by ranges.
+func complementRanges(ranges []Range, full Range) []Range {
+	var sorted = append([]Range(nil), ranges...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Lo < sorted[j].Lo })
+
+	var ret []Range
+	var next = uint64(full.Lo)
+	for _, r := range sorted {
+		if uint64(r.Lo) > next {
+			ret = append(ret, Range{uint32(next), r.Lo - 1})
+		}
+		if uint64(r.Hi)+1 > next {
+			next = uint64(r.Hi) + 1
+		}
+	}
+	if next <= uint64(full.Hi) {
+		ret = append(ret, Range{uint32(next), full.Hi})
+	}
+	return ret
+}
+
+// parseAddressRange parses a RouterOS IPv4 address, network or range (a.b.c.d-e.f.g.h).
+func parseAddressRange(value string) (Range, error) {
+	if _, n, err := net.ParseCIDR(value); err == nil {
+		if r, ok := ipv4Range(n); ok {
+			return r, nil
+		}
+		return Range{}, errors.New("unsupported address " + value)
+	}
+
+	var parts = strings.SplitN(value, "-", 2)
+	lo := net.ParseIP(parts[0]).To4()
+	hi := lo
+	if len(parts) == 2 {
+		hi = net.ParseIP(parts[1]).To4()
+	}
+	if lo == nil || hi == nil {
+		return Range{}, errors.New("unsupported address " + value)
+	}
+	return Range{ipToUint32(lo), ipToUint32(hi)}, nil
+}
+
+// parsePortRanges parses a RouterOS port list, e.g. "53,67-68".
+func parsePortRanges(value string) ([]Range, error) {
+	var ret []Range
+	for _, item := range strings.Split(value, ",") {
+		var parts = strings.SplitN(item, "-", 2)
+		lo, err := strconv.ParseUint(parts[0], 10, 16)
+		if err != nil {
+			return nil, errors.New("invalid port " + item)
+		}
+		hi := lo
+		if len(parts) == 2 {
+			hi, err = strconv.ParseUint(parts[1], 10, 16)
+			if err != nil {
+				return nil, errors.New("invalid port " + item)
+			}
+		}
+		ret = append(ret, Range{uint32(lo), uint32(hi)})
+	}
+	return ret, nil
+}
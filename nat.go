@@ -0,0 +1,44 @@
+package main
+
+import (
+	"net"
+)
+
+// VIPInterfaceIP is the VIP address meaning the address of the interface.
+const VIPInterfaceIP = "interface-ip"
+
+// MIP is a static one-to-one mapping of an address (or network) to a host, referenced as "MIP(address)" by the
+// policies.
+type MIP struct {
+	Interface string
+	Address   net.IP
+	Host      net.IP
+	Netmask   net.IPMask
+	VRouter   string
+}
+
+// VIP maps a port of an address to a port of a host, referenced as "VIP(address)" by the policies.
+type VIP struct {
+	Interface string
+	// Address is an IP address or VIPInterfaceIP
+	Address string
+	Port    int
+	Service string
+	Host    net.IP
+}
+
+// DIP is a pool of addresses for source NAT, referenced by ID by the "nat src dip-id" policies.
+type DIP struct {
+	Interface string
+	ID        int
+	Start     net.IP
+	End       net.IP
+	FixPort   bool
+}
+
+// NATConfig are the NAT objects defined on the interfaces.
+type NATConfig struct {
+	MIPs []MIP
+	VIPs []VIP
+	DIPs []DIP
+}
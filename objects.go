@@ -2,12 +2,15 @@ package main
 
 import (
 	"net"
+	"sort"
 	"strings"
 )
 
 type PolicyObject struct {
 	Address      *net.IPNet
+	Group        bool
 	GroupMembers []string
+	Provenance   Provenance
 }
 
 type Objects map[string]map[string]*PolicyObject
@@ -20,17 +23,48 @@ func (o Objects) Add(zone string, name string, address *net.IPNet) {
 	o[zone][name] = &PolicyObject{Address: address}
 }
 
-func (o Objects) AddToGroup(zone string, name string, objectToAdd string) {
+// AddGroup creates an empty group, if it doesn't exist yet.
+func (o Objects) AddGroup(zone string, name string) {
 	if _, ok := o[zone]; !ok {
 		o[zone] = make(map[string]*PolicyObject)
-		o[zone][name] = &PolicyObject{}
 	}
 	if _, ok := o[zone][name]; !ok {
-		o[zone][name] = &PolicyObject{}
+		o[zone][name] = &PolicyObject{Group: true}
 	}
+}
+
+func (o Objects) AddToGroup(zone string, name string, objectToAdd string) {
+	o.AddGroup(zone, name)
 	o[zone][name].GroupMembers = append(o[zone][name].GroupMembers, objectToAdd)
 }
 
+func (o Objects) Zones() []string {
+	var ret = make([]string, 0, len(o))
+	for zone := range o {
+		ret = append(ret, zone)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+func (o Objects) Names(zone string) []string {
+	var ret = make([]string, 0, len(o[zone]))
+	for name := range o[zone] {
+		ret = append(ret, name)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// defined tells whether Lookup knows the name: Any, a MIP or VIP, or an address or group of the zone.
+func (o Objects) defined(zone string, name string) bool {
+	if strings.ToLower(name) == "any" || strings.HasPrefix(name, "MIP(") || strings.HasPrefix(name, "VIP(") {
+		return true
+	}
+	_, ok := o[zone][name]
+	return ok
+}
+
 func (o Objects) Lookup(zone string, name string) ([]string, []*net.IPNet) {
 	if strings.ToLower(name) == "any" {
 		return []string{name}, []*net.IPNet{{IP: net.IPv4zero, Mask: net.IPMask(net.IPv4zero)}}
@@ -55,7 +89,7 @@ func (o Objects) Lookup(zone string, name string) ([]string, []*net.IPNet) {
 		return nil, nil
 	}
 
-	if len(o[zone][name].GroupMembers) > 0 {
+	if o[zone][name].Group {
 		var names []string
 		var ret []*net.IPNet
 		for _, obj := range o[zone][name].GroupMembers {
@@ -64,16 +98,13 @@ func (o Objects) Lookup(zone string, name string) ([]string, []*net.IPNet) {
 			ret = append(ret, addresses...)
 		}
 
+		var seen = make(map[string]int8, len(ret))
 		var dedup []*net.IPNet
 		var dedupNames []string
 		for idx, ip := range ret {
-			var found = false
-			for _, newip := range dedup {
-				if ip.IP.Equal(newip.IP) && ip.Mask.String() == newip.Mask.String() {
-					found = true
-				}
-			}
-			if !found {
+			key := ip.IP.To16().String() + "/" + ip.Mask.String()
+			if _, found := seen[key]; !found {
+				seen[key] = 1
 				dedup = append(dedup, ip)
 				dedupNames = append(dedupNames, names[idx])
 			}
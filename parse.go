@@ -15,7 +15,7 @@ import (
 var setAddressRx = regexp.MustCompile("^set address \"([^\"]+)\" \"([^\"]+)\" ([^ ]+) ?([^ ]+)?( \"([^\"]+)\")?$")
 var setGroupAddressRx = regexp.MustCompile("^set group address \"([^\"]+)\" \"([^\"]+)\" add \"([^\"]+)\"$")
 var setGroupAddressCreateRx = regexp.MustCompile("^set group address \"([^\"]+)\" \"([^\"]+)\"( comment .*)?$")
-var setPolicyCreateRx = regexp.MustCompile("^set policy id ([0-9]+) (name \"([^\"]+)\" )?from \"([^\"]+)\" to \"([^\"]+)\" {2}\"([^\"]+)\" \"([^\"]+)\" \"([^\"]+)\" (nat src|nat dst)?( ip ([^ ]+))?( port ([0-9]+))? ?(permit|deny|reject) ?(log)?( traffic mbw 2000)?( schedule \"[^\"]+\")?")
+var setPolicyCreateRx = regexp.MustCompile("^set policy id ([0-9]+) (name \"([^\"]+)\" )?from \"([^\"]+)\" to \"([^\"]+)\" {2}\"([^\"]+)\" \"([^\"]+)\" \"([^\"]+)\" (nat src|nat dst)?( ip ([^ ]+))?( port ([0-9]+))? ?(permit|deny|reject|tunnel)( vpn \"([^\"]+)\")?( id 0x[0-9a-fA-F]+)?( pair-policy ([0-9]+))? ?(log)?( traffic mbw 2000)?( schedule \"[^\"]+\")?")
 var setPolicyRx = regexp.MustCompile("^set policy id ([0-9]+)$")
 var setPolicyFlagsRx = regexp.MustCompile("^set policy id ([0-9]+) (disable|application) ?(\"([^\"]+)\")?$")
 var setPolicyServiceRx = regexp.MustCompile("^set (service|dst-address|src-address) \"([^\"]+)\"$")
@@ -23,16 +23,61 @@ var setLogOptionsRx = regexp.MustCompile("^set log (.*)$")
 var setServiceRx = regexp.MustCompile("^set service \"([^\"]+)\" protocol (tcp|udp|50|51) src-port ([0-9]+)-([0-9]+) dst-port ([0-9]+)-([0-9]+)( timeout [0-9]+)?$")
 var setServiceContinueRx = regexp.MustCompile("^set service \"([^\"]+)\" \\+ (tcp|udp|50|51) src-port ([0-9]+)-([0-9]+) dst-port ([0-9]+)-([0-9]+)( timeout [0-9]+)?$")
 var setServiceTimeoutRx = regexp.MustCompile("^set service \"([^\"]+)\" (timeout [0-9]+|session-cache)$")
+var setVRouterRx = regexp.MustCompile("^set vrouter \"([^\"]+)\"$")
+var setVRouterCreateRx = regexp.MustCompile("^set vrouter name \"([^\"]+)\"( id [0-9]+)?( sharable)?$")
+var setVRouterProtocolRx = regexp.MustCompile("^set protocol [a-z]+( [0-9]+)?$")
+var setInterfaceZoneRx = regexp.MustCompile("^set interface \"?([^\" ]+)\"?( tag ([0-9]+))? zone \"([^\"]+)\"$")
+var setInterfaceIPRx = regexp.MustCompile("^set interface \"?([^\" ]+)\"? ip ([0-9.]+/[0-9]+)( secondary)?$")
+var setInterfaceModeRx = regexp.MustCompile("^set interface \"?([^\" ]+)\"? (route|nat)$")
+var setInterfaceManageRx = regexp.MustCompile("^set interface \"?([^\" ]+)\"? manage ([a-z-]+)$")
+var setInterfaceDHCPServerRx = regexp.MustCompile("^set interface \"?([^\" ]+)\"? dhcp server (service|enable|auto)$")
+var setInterfaceDHCPRangeRx = regexp.MustCompile("^set interface \"?([^\" ]+)\"? dhcp server ip ([0-9.]+) to ([0-9.]+)$")
+var setInterfaceDHCPReservationRx = regexp.MustCompile("^set interface \"?([^\" ]+)\"? dhcp server ip ([0-9.]+) mac ([0-9a-fA-F.:]+)$")
+var setInterfaceDHCPOptionRx = regexp.MustCompile("^set interface \"?([^\" ]+)\"? dhcp server option ([a-z0-9]+) \"?([^\"]+)\"?$")
+var setInterfaceMIPRx = regexp.MustCompile("^set interface \"?([^\" ]+)\"? mip ([0-9.]+) host ([0-9.]+) netmask ([0-9.]+)( vr \"([^\"]+)\")?$")
+var setInterfaceVIPRx = regexp.MustCompile("^set interface \"?([^\" ]+)\"? vip (interface-ip|[0-9.]+)( \\+)? ([0-9]+) \"([^\"]+)\" ([0-9.]+)( manual)?$")
+var setInterfaceDIPRx = regexp.MustCompile("^set interface \"?([^\" ]+)\"?( ext ip [0-9.]+ [0-9.]+)? dip ([0-9]+) ([0-9.]+) ([0-9.]+)( fix-port)?$")
+var setInterfaceDHCPRelayRx = regexp.MustCompile("^set interface \"?([^\" ]+)\"? dhcp relay (service|server-name \"?([^\" ]+)\"?)$")
+var setAdminNameRx = regexp.MustCompile("^set admin name \"([^\"]+)\"$")
+var setAdminUserRx = regexp.MustCompile("^set admin user \"([^\"]+)\" password \"[^\"]*\"( privilege \"([^\"]+)\")?$")
+var setAdminManagerIPRx = regexp.MustCompile("^set admin manager-ip ([0-9.]+) ([0-9.]+)$")
+var setAdminAuthServerRx = regexp.MustCompile("^set admin auth server \"([^\"]+)\"$")
+var setAuthServerRx = regexp.MustCompile("^set auth-server \"([^\"]+)\" (id [0-9]+|server-name \"([^\"]+)\"|backup[12] \"([^\"]+)\"|account-type (.+)|(radius|ldap|securid) (port ([0-9]+)|.+)|.+)$")
+var setUserRx = regexp.MustCompile("^set user \"([^\"]+)\" (uid [0-9]+|type (.+)|hash-password \"[^\"]*\"|password \"[^\"]*\"|\"(enable|disable)\")$")
+var setZoneScreenRx = regexp.MustCompile("^set zone \"([^\"]+)\" screen ([a-z-]+)( ([a-z-]+))?( ([0-9]+))?$")
+var setIKEP1ProposalRx = regexp.MustCompile("^set ike p1-proposal \"([^\"]+)\" (preshare|rsa-sig|dsa-sig) group([0-9]+) esp (des|3des|aes128|aes192|aes256) (md5|sha-1|sha2-256|sha2-384|sha2-512)( (second|minute|hour|day) ([0-9]+))?$")
+var setIKEP2ProposalRx = regexp.MustCompile("^set ike p2-proposal \"([^\"]+)\" (no-pfs|group([0-9]+)) (esp|ah)( (des|3des|aes128|aes192|aes256|null))? (md5|sha-1|sha2-256|sha2-384|sha2-512|null)( (second|minute|hour|day) ([0-9]+))?( kbyte [0-9]+)?$")
+var setIKEGatewayRx = regexp.MustCompile("^set ike gateway \"([^\"]+)\" (address ([^ ]+)|dynamic \"?([^\" ]+)\"?) (Main|Aggr)( local-id \"([^\"]*)\")? outgoing-interface \"?([^\" ]+)\"?( preshare \"[^\"]*\")? (proposal ((?: ?\"[^\"]+\")+)|sec-level ([a-z]+))$")
+var setIKEGatewayOptionRx = regexp.MustCompile("^set ike gateway \"([^\"]+)\" (.+)$")
+var setVPNRx = regexp.MustCompile("^set vpn \"([^\"]+)\" gateway \"([^\"]+)\" (no-replay|replay) tunnel idletime [0-9]+ (proposal ((?: ?\"[^\"]+\")+)|sec-level ([a-z]+))$")
+var setVPNBindRx = regexp.MustCompile("^set vpn \"([^\"]+)\"( id 0x[0-9a-fA-F]+)? bind interface ([^ ]+)$")
+var setVPNProxyIDRx = regexp.MustCompile("^set vpn \"([^\"]+)\" proxy-id( check)? local-ip ([0-9.]+/[0-9]+) remote-ip ([0-9.]+/[0-9]+) \"([^\"]+)\"$")
+var setVPNOptionRx = regexp.MustCompile("^set vpn \"([^\"]+)\" (.+)$")
+var quotedRx = regexp.MustCompile("\"([^\"]+)\"")
+var setRouteRx = regexp.MustCompile("^set route ([0-9a-fA-F.:]+/[0-9]+)( interface ([^ ]+))?( gateway ([0-9a-fA-F.:]+))?( vrouter \"([^\"]+)\")?( preference ([0-9]+))?( permanent)?( metric ([0-9]+))?( tag ([0-9]+))?( description \"([^\"]*)\")?$")
 
-func parse(reader io.Reader) ([]Policy, Objects, Services) {
+// parse reads a ScreenOS configuration. The file name is recorded in the Provenance of the policies, address objects
+// and services.
+func parse(reader io.Reader, file string) Config {
 	var policies []Policy
 	var objects = make(Objects)
 	var services = defaultServices()
+	var vrouters []string
+	var routes []Route
+	var interfaces = make(Interfaces)
+	var vpn = newVPNConfig()
+	var admin = newAdminConfig()
+	var screens = make(Screens)
+	var nat NATConfig
 
 	var lastService = ""
+	var vrouter = DefaultVRouter
+	var vrouterProtocolDepth = 0
 
+	var lineNumber = 0
 	var scanner = bufio.NewScanner(reader)
 	for scanner.Scan() {
+		lineNumber++
 		var line = strings.TrimSpace(scanner.Text())
 		switch {
 		case setServiceContinueRx.MatchString(line):
@@ -43,6 +88,7 @@ func parse(reader io.Reader) ([]Policy, Objects, Services) {
 				SrcPortEnd:   mustInt(parts[0][4]),
 				DstPortStart: mustInt(parts[0][5]),
 				DstPortEnd:   mustInt(parts[0][6]),
+				Provenance:   Provenance{File: file, Lines: []LineRange{{lineNumber, lineNumber}}},
 			})
 		case setServiceRx.MatchString(line):
 			parts := setServiceRx.FindAllStringSubmatch(line, -1)
@@ -53,6 +99,7 @@ func parse(reader io.Reader) ([]Policy, Objects, Services) {
 				SrcPortEnd:   mustInt(parts[0][4]),
 				DstPortStart: mustInt(parts[0][5]),
 				DstPortEnd:   mustInt(parts[0][6]),
+				Provenance:   Provenance{File: file, Lines: []LineRange{{lineNumber, lineNumber}}},
 			})
 		case setServiceTimeoutRx.MatchString(line):
 			continue
@@ -85,15 +132,18 @@ func parse(reader io.Reader) ([]Policy, Objects, Services) {
 			}
 
 			objects.Add(parts[0][1], parts[0][2], &ip)
+			objects[parts[0][1]][parts[0][2]].Provenance.Add(file, lineNumber)
 		case strings.HasPrefix(line, "set address"):
 			panic(line)
 
 		case setGroupAddressRx.MatchString(line):
 			parts := setGroupAddressRx.FindAllStringSubmatch(line, -1)
 			objects.AddToGroup(parts[0][1], parts[0][2], parts[0][3])
+			objects[parts[0][1]][parts[0][2]].Provenance.Add(file, lineNumber)
 		case setGroupAddressCreateRx.MatchString(line):
-			// Skip group creation
-			continue
+			parts := setGroupAddressCreateRx.FindAllStringSubmatch(line, -1)
+			objects.AddGroup(parts[0][1], parts[0][2])
+			objects[parts[0][1]][parts[0][2]].Provenance.Add(file, lineNumber)
 		case strings.HasPrefix(line, "set group address"):
 			panic(line)
 
@@ -125,16 +175,22 @@ func parse(reader io.Reader) ([]Policy, Objects, Services) {
 				NATAddress:   parts[0][11],
 				NATPort:      natPort,
 				Action:       parts[0][14],
-				Log:          parts[0][15] == "log",
+				VPN:          parts[0][16],
+				Log:          parts[0][20] == "log",
 				LogInit:      false,
 				Disabled:     false,
 			}
 
+			if parts[0][19] != "" {
+				p.PairPolicy = mustInt(parts[0][19])
+			}
+
 			if !p.IsValid() {
 				_ = json.NewEncoder(os.Stdout).Encode(p)
 				panic("not valid")
 			}
 
+			p.Provenance.Add(file, lineNumber)
 			policies = append(policies, p)
 		case setPolicyRx.MatchString(line):
 			parts := setPolicyRx.FindAllStringSubmatch(line, -1)
@@ -160,8 +216,11 @@ func parse(reader io.Reader) ([]Policy, Objects, Services) {
 				panic("policy not found: " + line)
 			}
 
+			policies[policy].Provenance.Add(file, lineNumber)
 			for scanner.Scan() {
+				lineNumber++
 				line = scanner.Text()
+				policies[policy].Provenance.Add(file, lineNumber)
 
 				if line == "exit" {
 					break
@@ -223,6 +282,7 @@ func parse(reader io.Reader) ([]Policy, Objects, Services) {
 				panic("policy not found: " + line)
 			}
 
+			policies[policy].Provenance.Add(file, lineNumber)
 			switch parts[0][2] {
 			case "disable":
 				policies[policy].Disabled = true
@@ -234,10 +294,303 @@ func parse(reader io.Reader) ([]Policy, Objects, Services) {
 
 		case strings.HasPrefix(line, "set policy id"):
 			panic(line)
+
+		case setVRouterRx.MatchString(line):
+			parts := setVRouterRx.FindAllStringSubmatch(line, -1)
+			vrouter = parts[0][1]
+			vrouters = appendUnique(vrouters, vrouter)
+		case setVRouterCreateRx.MatchString(line):
+			parts := setVRouterCreateRx.FindAllStringSubmatch(line, -1)
+			vrouters = appendUnique(vrouters, parts[0][1])
+		case setVRouterProtocolRx.MatchString(line):
+			// Dynamic routing protocols are not converted, however their blocks are terminated by "exit" as well
+			vrouterProtocolDepth++
+		case line == "exit":
+			if vrouterProtocolDepth > 0 {
+				vrouterProtocolDepth--
+			} else {
+				vrouter = DefaultVRouter
+			}
+
+		case setRouteRx.MatchString(line):
+			parts := setRouteRx.FindAllStringSubmatch(line, -1)
+			_, prefix, err := net.ParseCIDR(parts[0][1])
+			if err != nil {
+				panic(err)
+			}
+
+			r := Route{
+				VRouter:     vrouter,
+				Prefix:      prefix,
+				Interface:   parts[0][3],
+				NextVRouter: parts[0][7],
+				Description: parts[0][16],
+				Permanent:   parts[0][10] != "",
+			}
+			if parts[0][5] != "" {
+				r.Gateway = net.ParseIP(parts[0][5])
+			}
+			if parts[0][9] != "" {
+				r.Preference = mustInt(parts[0][9])
+			}
+			if parts[0][12] != "" {
+				r.Metric = mustInt(parts[0][12])
+			}
+			if parts[0][14] != "" {
+				r.Tag = mustInt(parts[0][14])
+			}
+
+			vrouters = appendUnique(vrouters, vrouter)
+			routes = append(routes, r)
+		case strings.HasPrefix(line, "set route "):
+			panic(line)
+
+		case setIKEP1ProposalRx.MatchString(line):
+			parts := setIKEP1ProposalRx.FindAllStringSubmatch(line, -1)
+			vpn.P1Proposals[parts[0][1]] = Proposal{
+				Name:       parts[0][1],
+				Auth:       parts[0][2],
+				DHGroup:    mustInt(parts[0][3]),
+				Protocol:   "esp",
+				Encryption: parts[0][4],
+				Hash:       parts[0][5],
+				Lifetime:   lifetimeSeconds(parts[0][7], parts[0][8], 28800),
+			}
+		case setIKEP2ProposalRx.MatchString(line):
+			parts := setIKEP2ProposalRx.FindAllStringSubmatch(line, -1)
+			p := Proposal{
+				Name:       parts[0][1],
+				Protocol:   parts[0][4],
+				Encryption: parts[0][6],
+				Hash:       parts[0][7],
+				Lifetime:   lifetimeSeconds(parts[0][9], parts[0][10], 3600),
+			}
+			if parts[0][3] != "" {
+				p.DHGroup = mustInt(parts[0][3])
+			}
+			vpn.P2Proposals[p.Name] = p
+		case setIKEGatewayRx.MatchString(line):
+			parts := setIKEGatewayRx.FindAllStringSubmatch(line, -1)
+			gw := vpn.Gateway(parts[0][1])
+			gw.Address = parts[0][3]
+			if parts[0][4] != "" {
+				gw.Address = parts[0][4]
+				gw.Dynamic = true
+			}
+			gw.Mode = parts[0][5]
+			gw.LocalID = parts[0][7]
+			gw.OutgoingInterface = parts[0][8]
+			gw.Preshared = parts[0][9] != ""
+			for _, m := range quotedRx.FindAllStringSubmatch(parts[0][11], -1) {
+				gw.Proposals = append(gw.Proposals, m[1])
+			}
+			gw.SecLevel = parts[0][12]
+		case setIKEGatewayOptionRx.MatchString(line):
+			parts := setIKEGatewayOptionRx.FindAllStringSubmatch(line, -1)
+			gw := vpn.Gateway(parts[0][1])
+			gw.Unsupported = append(gw.Unsupported, parts[0][2])
+		case setVPNRx.MatchString(line):
+			parts := setVPNRx.FindAllStringSubmatch(line, -1)
+			v := vpn.VPN(parts[0][1])
+			v.Gateway = parts[0][2]
+			v.Replay = parts[0][3] == "replay"
+			for _, m := range quotedRx.FindAllStringSubmatch(parts[0][5], -1) {
+				v.Proposals = append(v.Proposals, m[1])
+			}
+			v.SecLevel = parts[0][6]
+		case setVPNBindRx.MatchString(line):
+			parts := setVPNBindRx.FindAllStringSubmatch(line, -1)
+			vpn.VPN(parts[0][1]).BindInterface = parts[0][3]
+		case setVPNProxyIDRx.MatchString(line):
+			parts := setVPNProxyIDRx.FindAllStringSubmatch(line, -1)
+			_, local, err := net.ParseCIDR(parts[0][3])
+			if err != nil {
+				panic(err)
+			}
+			_, remote, err := net.ParseCIDR(parts[0][4])
+			if err != nil {
+				panic(err)
+			}
+			v := vpn.VPN(parts[0][1])
+			v.ProxyIDs = append(v.ProxyIDs, ProxyID{Local: local, Remote: remote, Service: parts[0][5]})
+		case setVPNOptionRx.MatchString(line):
+			parts := setVPNOptionRx.FindAllStringSubmatch(line, -1)
+			v := vpn.VPN(parts[0][1])
+			v.Unsupported = append(v.Unsupported, parts[0][2])
+
+		case setAdminNameRx.MatchString(line):
+			parts := setAdminNameRx.FindAllStringSubmatch(line, -1)
+			admin.Admins[parts[0][1]] = &Admin{Name: parts[0][1], Privilege: PrivilegeAll, Root: true}
+		case setAdminUserRx.MatchString(line):
+			parts := setAdminUserRx.FindAllStringSubmatch(line, -1)
+			admin.Admins[parts[0][1]] = &Admin{Name: parts[0][1], Privilege: parts[0][3]}
+			if parts[0][3] == "" {
+				admin.Admins[parts[0][1]].Privilege = PrivilegeReadOnly
+			}
+		case setAdminManagerIPRx.MatchString(line):
+			parts := setAdminManagerIPRx.FindAllStringSubmatch(line, -1)
+			ip := net.IPNet{
+				IP:   net.ParseIP(parts[0][1]).To4(),
+				Mask: net.IPMask(net.ParseIP(parts[0][2]).To4()),
+			}
+			if ip.IP == nil || ip.Mask == nil {
+				panic(line)
+			}
+			admin.ManagerIPs = append(admin.ManagerIPs, &ip)
+		case setAdminAuthServerRx.MatchString(line):
+			parts := setAdminAuthServerRx.FindAllStringSubmatch(line, -1)
+			admin.AuthServer = parts[0][1]
+		case setAuthServerRx.MatchString(line):
+			parts := setAuthServerRx.FindAllStringSubmatch(line, -1)
+			srv := admin.AuthServerByName(parts[0][1])
+			switch {
+			case strings.HasPrefix(parts[0][2], "id "):
+				continue
+			case parts[0][3] != "":
+				srv.Servers = append([]string{parts[0][3]}, srv.Servers...)
+			case parts[0][4] != "":
+				srv.Servers = append(srv.Servers, parts[0][4])
+			case parts[0][5] != "":
+				srv.AccountTypes = strings.Fields(parts[0][5])
+			case parts[0][6] != "":
+				srv.Type = parts[0][6]
+				if parts[0][8] != "" {
+					srv.Port = mustInt(parts[0][8])
+				} else if !strings.HasPrefix(parts[0][7], "secret ") {
+					srv.Unsupported = append(srv.Unsupported, parts[0][2])
+				}
+			default:
+				srv.Unsupported = append(srv.Unsupported, parts[0][2])
+			}
+		case setUserRx.MatchString(line):
+			parts := setUserRx.FindAllStringSubmatch(line, -1)
+			user := admin.User(parts[0][1])
+			if parts[0][3] != "" {
+				user.Types = strings.Fields(parts[0][3])
+			}
+			if parts[0][4] != "" {
+				user.Disabled = parts[0][4] == "disable"
+			}
+
+		case setZoneScreenRx.MatchString(line):
+			parts := setZoneScreenRx.FindAllStringSubmatch(line, -1)
+			screen := screens.Get(parts[0][1])
+			var value = 0
+			if parts[0][6] != "" {
+				value = mustInt(parts[0][6])
+			}
+			if !screen.Set(parts[0][2], parts[0][4], value) {
+				screen.Unsupported = append(screen.Unsupported, strings.TrimSpace(parts[0][2]+" "+parts[0][4]))
+			}
+
+		// Other "set interface" settings (MTU, bandwidth, ...) are not converted, and they are too many to panic on
+		case setInterfaceZoneRx.MatchString(line):
+			parts := setInterfaceZoneRx.FindAllStringSubmatch(line, -1)
+			iface := interfaces.Get(parts[0][1])
+			if parts[0][3] != "" {
+				iface.Tag = mustInt(parts[0][3])
+			}
+			iface.Zone = parts[0][4]
+		case setInterfaceIPRx.MatchString(line):
+			parts := setInterfaceIPRx.FindAllStringSubmatch(line, -1)
+			ip, ipnet, err := net.ParseCIDR(parts[0][2])
+			if err != nil {
+				panic(err)
+			}
+			iface := interfaces.Get(parts[0][1])
+			iface.Addresses = append(iface.Addresses, &net.IPNet{IP: ip, Mask: ipnet.Mask})
+		case setInterfaceModeRx.MatchString(line):
+			parts := setInterfaceModeRx.FindAllStringSubmatch(line, -1)
+			interfaces.Get(parts[0][1]).Mode = parts[0][2]
+		case setInterfaceManageRx.MatchString(line):
+			parts := setInterfaceManageRx.FindAllStringSubmatch(line, -1)
+			iface := interfaces.Get(parts[0][1])
+			iface.Manage = append(iface.Manage, parts[0][2])
+		case setInterfaceDHCPServerRx.MatchString(line):
+			parts := setInterfaceDHCPServerRx.FindAllStringSubmatch(line, -1)
+			dhcp := interfaces.Get(parts[0][1]).DHCP()
+			dhcp.Enabled = dhcp.Enabled || parts[0][2] == "service"
+		case setInterfaceDHCPRangeRx.MatchString(line):
+			parts := setInterfaceDHCPRangeRx.FindAllStringSubmatch(line, -1)
+			dhcp := interfaces.Get(parts[0][1]).DHCP()
+			dhcp.Ranges = append(dhcp.Ranges, DHCPRange{Start: net.ParseIP(parts[0][2]), End: net.ParseIP(parts[0][3])})
+		case setInterfaceDHCPReservationRx.MatchString(line):
+			parts := setInterfaceDHCPReservationRx.FindAllStringSubmatch(line, -1)
+			dhcp := interfaces.Get(parts[0][1]).DHCP()
+			dhcp.Reservations = append(dhcp.Reservations, DHCPReservation{IP: net.ParseIP(parts[0][2]), MAC: parts[0][3]})
+		case setInterfaceDHCPOptionRx.MatchString(line):
+			parts := setInterfaceDHCPOptionRx.FindAllStringSubmatch(line, -1)
+			dhcp := interfaces.Get(parts[0][1]).DHCP()
+			switch parts[0][2] {
+			case "gateway":
+				dhcp.Gateway = net.ParseIP(parts[0][3])
+			case "netmask":
+				dhcp.Netmask = net.IPMask(net.ParseIP(parts[0][3]).To4())
+			case "dns1", "dns2", "dns3":
+				dhcp.DNS = append(dhcp.DNS, net.ParseIP(parts[0][3]))
+			case "domainname":
+				dhcp.Domain = parts[0][3]
+			case "lease":
+				dhcp.Lease = mustInt(parts[0][3])
+			default:
+				_, _ = fmt.Fprintln(os.Stderr, parts[0][1]+": dhcp server option "+parts[0][2]+" not converted")
+			}
+		case setInterfaceMIPRx.MatchString(line):
+			parts := setInterfaceMIPRx.FindAllStringSubmatch(line, -1)
+			var mip = MIP{Interface: parts[0][1], Address: net.ParseIP(parts[0][2]), Host: net.ParseIP(parts[0][3]),
+				Netmask: net.IPMask(net.ParseIP(parts[0][4]).To4()), VRouter: parts[0][6]}
+			if mip.VRouter == "" {
+				mip.VRouter = DefaultVRouter
+			}
+			nat.MIPs = append(nat.MIPs, mip)
+		case setInterfaceVIPRx.MatchString(line):
+			parts := setInterfaceVIPRx.FindAllStringSubmatch(line, -1)
+			nat.VIPs = append(nat.VIPs, VIP{Interface: parts[0][1], Address: parts[0][2], Port: mustInt(parts[0][4]),
+				Service: parts[0][5], Host: net.ParseIP(parts[0][6])})
+		case setInterfaceDIPRx.MatchString(line):
+			parts := setInterfaceDIPRx.FindAllStringSubmatch(line, -1)
+			nat.DIPs = append(nat.DIPs, DIP{Interface: parts[0][1], ID: mustInt(parts[0][3]), Start: net.ParseIP(parts[0][4]),
+				End: net.ParseIP(parts[0][5]), FixPort: parts[0][6] != ""})
+		case setInterfaceDHCPRelayRx.MatchString(line):
+			parts := setInterfaceDHCPRelayRx.FindAllStringSubmatch(line, -1)
+			iface := interfaces.Get(parts[0][1])
+			if parts[0][2] == "service" {
+				iface.DHCPRelay = true
+			} else {
+				iface.DHCPRelayServers = append(iface.DHCPRelayServers, parts[0][3])
+			}
 		}
 	}
 
-	return policies, objects, services
+	return Config{
+		Policies: policies,
+		Objects:  objects,
+		Services: services,
+		VRouters: vrouters,
+		Routes:   routes,
+
+		Interfaces: interfaces,
+		VPN:        vpn,
+		Admin:      admin,
+		Screens:    screens,
+		NAT:        nat,
+	}
+}
+
+// lifetimeSeconds converts a ScreenOS proposal lifetime to seconds.
+func lifetimeSeconds(unit string, value string, defaultValue int) int {
+	switch unit {
+	case "second":
+		return mustInt(value)
+	case "minute":
+		return mustInt(value) * 60
+	case "hour":
+		return mustInt(value) * 3600
+	case "day":
+		return mustInt(value) * 86400
+	default:
+		return defaultValue
+	}
 }
 
 func mustInt(s string) int {
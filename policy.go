@@ -9,36 +9,43 @@ const (
 	ActionPermit = "permit"
 	ActionReject = "reject"
 	ActionDeny   = "deny"
+	ActionTunnel = "tunnel"
 	NatSrc       = "nat src"
 	NatDst       = "nat dst"
+	ZoneGlobal   = "Global"
 )
 
 type Policy struct {
-	ID       int
-	Name     string
-	Disabled bool
+	ID       int    `json:"id" yaml:"id"`
+	Name     string `json:"name,omitempty" yaml:"name,omitempty"`
+	Disabled bool   `json:"disabled,omitempty" yaml:"disabled,omitempty"`
 
 	// Zones
-	From string
-	To   string
+	From string `json:"from" yaml:"from"`
+	To   string `json:"to" yaml:"to"`
 
 	// Addresses
-	Sources      []string
-	Destinations []string
+	Sources      []string `json:"sources" yaml:"sources"`
+	Destinations []string `json:"destinations" yaml:"destinations"`
 
 	// Ports/protocols
-	Services    []string
-	Application string
+	Services    []string `json:"services" yaml:"services"`
+	Application string   `json:"application,omitempty" yaml:"application,omitempty"`
 
 	// NAT
-	NAT        string
-	NATAddress string
-	NATPort    int
+	NAT        string `json:"nat,omitempty" yaml:"nat,omitempty"`
+	NATAddress string `json:"nat_address,omitempty" yaml:"nat_address,omitempty"`
+	NATPort    int    `json:"nat_port,omitempty" yaml:"nat_port,omitempty"`
 
 	// Actions
-	Action  string
-	Log     bool
-	LogInit bool
+	Action     string `json:"action" yaml:"action"`
+	VPN        string `json:"vpn,omitempty" yaml:"vpn,omitempty"`
+	PairPolicy int    `json:"pair_policy,omitempty" yaml:"pair_policy,omitempty"`
+	Log        bool   `json:"log,omitempty" yaml:"log,omitempty"`
+	LogInit    bool   `json:"log_init,omitempty" yaml:"log_init,omitempty"`
+
+	// Provenance is not part of the exported model, nor compared by Equals
+	Provenance Provenance `json:"-" yaml:"-"`
 }
 
 func (p *Policy) IsValid() bool {
@@ -48,7 +55,8 @@ func (p *Policy) IsValid() bool {
 		len(p.Sources) > 0 &&
 		len(p.Destinations) > 0 &&
 		len(p.Services) > 0 &&
-		(p.Action == ActionPermit || p.Action == ActionReject || p.Action == ActionDeny) &&
+		(p.Action == ActionPermit || p.Action == ActionReject || p.Action == ActionDeny ||
+			(p.Action == ActionTunnel && len(p.VPN) > 0)) &&
 		(p.NAT == "" || p.NAT == NatSrc || p.NAT == NatDst)
 }
 
@@ -75,6 +83,8 @@ func (p *Policy) Equals(q *Policy) bool {
 		p.NATAddress == q.NATAddress &&
 		p.NATPort == q.NATPort &&
 		p.Action == q.Action &&
+		p.VPN == q.VPN &&
+		p.PairPolicy == q.PairPolicy &&
 		p.Log == q.Log &&
 		p.LogInit == q.LogInit
 }
@@ -82,10 +92,15 @@ func (p *Policy) Equals(q *Policy) bool {
 func (p *Policy) String() string {
 	return fmt.Sprint("ID: ", p.ID, " Name: ", p.Name, " Disabled: ", p.Disabled, " From: ", p.From, " To: ", p.To,
 		" Sources: ", p.Sources, " Destinations: ", p.Destinations, " Services: ", p.Services, " Application: ", p.Application,
-		" NAT: ", p.NAT, " NATAddress: ", p.NATAddress, " NATPort: ", p.NATPort, " Action: ", p.Action, " Log: ", p.Log,
+		" NAT: ", p.NAT, " NATAddress: ", p.NATAddress, " NATPort: ", p.NATPort, " Action: ", p.Action, " VPN: ", p.VPN, " PairPolicy: ", p.PairPolicy, " Log: ", p.Log,
 		" LogInit: ", p.LogInit)
 }
 
+// Permits returns true if the policy lets the traffic through (possibly in a VPN tunnel).
+func (p *Policy) Permits() bool {
+	return p.Action == ActionPermit || p.Action == ActionTunnel
+}
+
 func (p *Policy) IsZonePolicy() bool {
 	return strings.ToLower(p.Sources[0]) == "any" && strings.ToLower(p.Destinations[0]) == "any" &&
 		(p.Action == ActionReject || p.Action == ActionDeny)
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Provenance is where a parsed entity was defined: the configuration file, and the ranges of its lines (e.g. the
+// definition of a policy, its "set policy id N" ... "exit" block and a later "disable" line).
+type Provenance struct {
+	File  string
+	Lines []LineRange
+}
+
+// LineRange is a range of line numbers, starting from 1, with both ends included.
+type LineRange struct {
+	Start int
+	End   int
+}
+
+// Add records a line, extending the last range if the line follows it.
+func (p *Provenance) Add(file string, line int) {
+	p.File = file
+	if n := len(p.Lines); n > 0 && line >= p.Lines[n-1].Start && line <= p.Lines[n-1].End+1 {
+		if line > p.Lines[n-1].End {
+			p.Lines[n-1].End = line
+		}
+		return
+	}
+	p.Lines = append(p.Lines, LineRange{Start: line, End: line})
+}
+
+// Merge records the lines of another entity of the same file, like the entries of a service.
+func (p *Provenance) Merge(q Provenance) {
+	for _, r := range q.Lines {
+		for line := r.Start; line <= r.End; line++ {
+			p.Add(q.File, line)
+		}
+	}
+}
+
+// String formats the provenance like "netscreen.cfg:26-31,40", or returns an empty string if unknown.
+func (p Provenance) String() string {
+	if len(p.Lines) == 0 {
+		return ""
+	}
+	var ranges = make([]string, len(p.Lines))
+	for idx, r := range p.Lines {
+		ranges[idx] = fmt.Sprint(r.Start)
+		if r.End > r.Start {
+			ranges[idx] += fmt.Sprint("-", r.End)
+		}
+	}
+	return p.File + ":" + strings.Join(ranges, ",")
+}
+
+// suffix returns the provenance in parentheses, preceded by a space, to be appended to a message.
+func (p Provenance) suffix() string {
+	if len(p.Lines) == 0 {
+		return ""
+	}
+	return " (" + p.String() + ")"
+}
+
+// text returns the lines of the given text recorded by the provenance.
+func (p Provenance) text(text string) []string {
+	var lines = strings.Split(text, "\n")
+	var ret []string
+	for _, r := range p.Lines {
+		for line := r.Start; line <= r.End && line <= len(lines); line++ {
+			ret = append(ret, strings.TrimSpace(lines[line-1]))
+		}
+	}
+	return ret
+}
+
+// serviceProvenance returns the lines of all the entries of a service.
+func serviceProvenance(sl ServiceList) Provenance {
+	var ret Provenance
+	for _, s := range sl {
+		ret.Merge(s.Provenance)
+	}
+	return ret
+}
+
+// provenanceMikrotik appends the provenance of the policy to the comment of each of its filter rules, like
+// "ID: 1 - Client-A -> Web1 (netscreen.cfg:26-31)".
+func provenanceMikrotik(rsc string, policies []Policy) string {
+	var provenances = make(map[string]string, len(policies))
+	for _, p := range policies {
+		provenances[fmt.Sprint(p.ID)] = p.Provenance.suffix()
+	}
+
+	var lines = strings.Split(rsc, "\n")
+	for idx, line := range lines {
+		m := commentArgRx.FindStringSubmatchIndex(line)
+		if !strings.HasPrefix(line, "add ") || m == nil {
+			continue
+		}
+		comment := rscUnquote(line[m[2]:m[3]])
+		if id := ruleIDRx.FindStringSubmatch(comment); id != nil && provenances[id[2]] != "" {
+			lines[idx] = line[:m[2]] + rscQuote(comment+provenances[id[2]]) + line[m[3]:]
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,429 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// apiReadOnly are the item properties returned by print which can't be given to add.
+var apiReadOnly = map[string]bool{"bytes": true, "packets": true, "dynamic": true, "invalid": true, "default": true,
+	"builtin": true, "creation-time": true, "running": true}
+
+var apiPickRx = regexp.MustCompile(`^\[:pick \[find(.*)\] 0\]$`)
+
+// apiExpressionRx matches the script expressions, which the API can't evaluate
+var apiExpressionRx = regexp.MustCompile(`^\[(find|:|/)`)
+
+// apiSelector selects items of a menu: the items matching Query ("?key=value" words, empty for every item) and the
+// Match regular expressions ("key~regexp" conditions, which have no API query), or the item at position Index if Query
+// is nil.
+type apiSelector struct {
+	Query []string
+	Match map[string]*regexp.Regexp
+	Index int
+}
+
+// apiCommand is a script command translated to the RouterOS API. Enable and disable become a set of "disabled".
+type apiCommand struct {
+	Path string
+	Verb string
+
+	// Select are the items to set or remove, nil for the single item of menus like /ip settings
+	Select      *apiSelector
+	Args        []string
+	PlaceBefore *apiSelector
+	Line        int
+}
+
+// apiUndo is a command undoing a change. Restores is the ID of the removed item a re-add restores, since the item
+// gets a new ID which must be used by the following undo commands.
+type apiUndo struct {
+	Words    []string
+	Restores string
+}
+
+// apiCommands translates the commands of a RouterOS script to API commands. Only the selectors and expressions
+// emitted by the converter are supported: [find k=v ...], item numbers and [:pick [find ...] 0].
+func apiCommands(commands []RscCommand) ([]apiCommand, error) {
+	var ret []apiCommand
+	for _, cmd := range commands {
+		if cmd.Menu == "" {
+			return nil, fmt.Errorf("line %d: command outside of a menu", cmd.Line)
+		}
+
+		var c = apiCommand{Path: strings.ReplaceAll(cmd.Menu, " ", "/"), Verb: cmd.Verb, Line: cmd.Line}
+		switch cmd.Verb {
+		case "add":
+			// Flags without value, like "fib" in /routing table
+			for _, flag := range strings.Fields(cmd.Find) {
+				c.Args = append(c.Args, "="+flag+"=")
+			}
+		case "set", "remove", "enable", "disable":
+			if cmd.Find != "" {
+				sel, err := apiSelectorFor(cmd.Find)
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %w", cmd.Line, err)
+				}
+				c.Select = &sel
+			} else if cmd.Verb != "set" {
+				return nil, fmt.Errorf("line %d: %s without items", cmd.Line, cmd.Verb)
+			}
+		default:
+			return nil, fmt.Errorf("line %d: unsupported command %s", cmd.Line, cmd.Verb)
+		}
+		switch cmd.Verb {
+		case "enable":
+			c.Verb = "set"
+			c.Args = append(c.Args, "=disabled=no")
+		case "disable":
+			c.Verb = "set"
+			c.Args = append(c.Args, "=disabled=yes")
+		}
+
+		for _, k := range cmd.Keys {
+			var v = cmd.Args[k]
+			if k == "place-before" && cmd.Verb == "add" {
+				var sel apiSelector
+				var err error
+				if m := apiPickRx.FindStringSubmatch(v); m != nil {
+					sel, err = apiSelectorFor("[find" + m[1] + "]")
+				} else {
+					sel, err = apiSelectorFor(v)
+				}
+				if err != nil {
+					return nil, fmt.Errorf("line %d: %w", cmd.Line, err)
+				}
+				c.PlaceBefore = &sel
+				continue
+			}
+			if apiExpressionRx.MatchString(v) {
+				return nil, fmt.Errorf("line %d: unsupported expression %s", cmd.Line, v)
+			}
+			c.Args = append(c.Args, "="+k+"="+v)
+		}
+		ret = append(ret, c)
+	}
+	return ret, nil
+}
+
+// apiSelectorFor translates an item number or a [find] expression with "key=value" and "key~regexp" conditions.
+func apiSelectorFor(find string) (apiSelector, error) {
+	if idx, err := strconv.Atoi(find); err == nil {
+		return apiSelector{Index: idx}, nil
+	}
+	if !strings.HasPrefix(find, "[find") || !strings.HasSuffix(find, "]") {
+		return apiSelector{}, errors.New("unsupported selector " + find)
+	}
+
+	var ret = apiSelector{Query: []string{}, Match: make(map[string]*regexp.Regexp)}
+	for _, token := range rscTokens(strings.TrimSuffix(strings.TrimPrefix(find, "[find"), "]")) {
+		if token == "where" {
+			continue
+		}
+		op := strings.IndexAny(token, "=~")
+		if op <= 0 || strings.HasPrefix(token, "[") {
+			return apiSelector{}, errors.New("unsupported selector " + find)
+		}
+		key, value := token[:op], rscUnquote(token[op+1:])
+		if token[op] == '=' {
+			ret.Query = append(ret.Query, "?"+key+"="+normalizeRscValue(key, value))
+			continue
+		}
+		rx, err := regexp.Compile(value)
+		if err != nil {
+			return apiSelector{}, err
+		}
+		ret.Match[key] = rx
+	}
+	return ret, nil
+}
+
+// String returns the sentences sent for the command, with the IDs of the selected items as placeholders.
+func (c apiCommand) String() string {
+	var ret strings.Builder
+	var ids = ""
+	if c.Select != nil {
+		ret.WriteString(c.Select.sentence(c.Path))
+		ids = "=.id=$ids"
+	}
+	var place = ""
+	if c.PlaceBefore != nil {
+		ret.WriteString(c.PlaceBefore.sentence(c.Path))
+		place = "=place-before=$first"
+	}
+
+	var words = append([]string{c.Path + "/" + c.Verb}, ids)
+	words = append(words, c.Args...)
+	words = append(words, place)
+	for _, w := range words {
+		if w != "" {
+			ret.WriteString(w + "\n")
+		}
+	}
+	ret.WriteString("\n")
+	return ret.String()
+}
+
+// words returns the print command of the selected items, with the properties of the Match conditions.
+func (s apiSelector) words(path string) []string {
+	var proplist = "=.proplist=" + strings.Join(append([]string{".id"}, s.matchKeys()...), ",")
+	return append([]string{path + "/print", proplist}, s.Query...)
+}
+
+func (s apiSelector) sentence(path string) string {
+	var ret = strings.Join(s.words(path), "\n") + "\n"
+	for _, k := range s.matchKeys() {
+		ret += fmt.Sprintf("# where %s~%s\n", k, s.Match[k].String())
+	}
+	if s.Query == nil {
+		ret += fmt.Sprintf("# item %d\n", s.Index)
+	}
+	return ret + "\n"
+}
+
+func (s apiSelector) matchKeys() []string {
+	var ret = make([]string, 0, len(s.Match))
+	for k := range s.Match {
+		ret = append(ret, k)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// resolve returns the IDs of the selected items.
+func (s apiSelector) resolve(api APIRunner, path string) ([]string, error) {
+	reply, err := api.Run(s.words(path)...)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for _, item := range reply.Re {
+		var match = true
+		for k, rx := range s.Match {
+			match = match && rx.MatchString(item[k])
+		}
+		if match {
+			ids = append(ids, item[".id"])
+		}
+	}
+	if s.Query != nil {
+		return ids, nil
+	}
+	if s.Index < 0 || s.Index >= len(ids) {
+		return nil, fmt.Errorf("%s: no item %d", path, s.Index)
+	}
+	return ids[s.Index : s.Index+1], nil
+}
+
+// pushMikrotik applies the commands through the RouterOS API like a transaction, as safe mode isn't available to API
+// sessions: the menus are snapshotted, every change is journaled, and the item counts are checked against the snapshot
+// at the end. On error or count mismatch, the journal is undone in reverse order.
+func pushMikrotik(api APIRunner, commands []apiCommand, log io.Writer) error {
+	var snapshot = make(map[string]int)
+	var paths []string
+	for _, c := range commands {
+		if _, ok := snapshot[c.Path]; ok || c.Select == nil && c.Verb == "set" {
+			continue
+		}
+		reply, err := api.Run(c.Path+"/print", "=.proplist=.id")
+		if err != nil {
+			return err
+		}
+		snapshot[c.Path] = len(reply.Re)
+		paths = append(paths, c.Path)
+		_, _ = fmt.Fprintf(log, "snapshot: %s has %d items\n", c.Path, len(reply.Re))
+	}
+
+	var journal []apiUndo
+	var expected = make(map[string]int, len(snapshot))
+	for k, v := range snapshot {
+		expected[k] = v
+	}
+
+	var err error
+	for _, c := range commands {
+		var undo []apiUndo
+		var delta int
+		undo, delta, err = applyAPICommand(api, c)
+		journal = append(journal, undo...)
+		if err != nil {
+			err = fmt.Errorf("line %d: %w", c.Line, err)
+			break
+		}
+		expected[c.Path] += delta
+	}
+
+	if err == nil {
+		sort.Strings(paths)
+		for _, path := range paths {
+			var reply APIReply
+			if reply, err = api.Run(path+"/print", "=.proplist=.id"); err != nil {
+				break
+			}
+			if len(reply.Re) != expected[path] {
+				err = fmt.Errorf("%s has %d items instead of %d", path, len(reply.Re), expected[path])
+				break
+			}
+		}
+	}
+	if err == nil {
+		_, _ = fmt.Fprintf(log, "applied %d commands\n", len(commands))
+		return nil
+	}
+
+	_, _ = fmt.Fprintf(log, "error: %s, rolling back %d changes\n", err.Error(), len(journal))
+	if rollbackErr := rollbackAPI(api, journal); rollbackErr != nil {
+		return fmt.Errorf("%w (rollback failed: %s)", err, rollbackErr.Error())
+	}
+	_, _ = fmt.Fprintln(log, "rolled back")
+	return err
+}
+
+// applyAPICommand runs a command, returning the commands undoing it and the change of the number of items.
+func applyAPICommand(api APIRunner, c apiCommand) ([]apiUndo, int, error) {
+	switch {
+	case c.Verb == "add":
+		var words = append([]string{c.Path + "/add"}, c.Args...)
+		if c.PlaceBefore != nil {
+			ids, err := c.PlaceBefore.resolve(api, c.Path)
+			if err != nil {
+				return nil, 0, err
+			}
+			if len(ids) > 0 {
+				words = append(words, "=place-before="+ids[0])
+			}
+		}
+		reply, err := api.Run(words...)
+		if err != nil {
+			return nil, 0, err
+		}
+		return []apiUndo{{Words: []string{c.Path + "/remove", "=.id=" + reply.Done["ret"]}}}, 1, nil
+	case c.Select == nil:
+		// Single item menu
+		reply, err := api.Run(c.Path + "/print")
+		if err != nil {
+			return nil, 0, err
+		}
+		var item = make(map[string]string)
+		if len(reply.Re) > 0 {
+			item = reply.Re[0]
+		}
+		if _, err := api.Run(append([]string{c.Path + "/set"}, c.Args...)...); err != nil {
+			return nil, 0, err
+		}
+		return apiRestore(c.Path, "", item, c.Args), 0, nil
+	}
+
+	ids, err := c.Select.resolve(api, c.Path)
+	if err != nil {
+		return nil, 0, err
+	}
+	reply, err := api.Run(c.Path + "/print")
+	if err != nil {
+		return nil, 0, err
+	}
+	var items = make(map[string]int, len(reply.Re))
+	for idx, item := range reply.Re {
+		items[item[".id"]] = idx
+	}
+
+	var ret []apiUndo
+	for _, id := range ids {
+		if _, ok := items[id]; !ok {
+			return ret, 0, fmt.Errorf("%s: no item %s", c.Path, id)
+		}
+		var item = reply.Re[items[id]]
+		if c.Verb == "set" {
+			if _, err := api.Run(append([]string{c.Path + "/set", "=.id=" + id}, c.Args...)...); err != nil {
+				return ret, 0, err
+			}
+			ret = append(ret, apiRestore(c.Path, id, item, c.Args)...)
+			continue
+		}
+
+		if _, err := api.Run(c.Path+"/remove", "=.id="+id); err != nil {
+			return ret, 0, err
+		}
+		var words = []string{c.Path + "/add"}
+		var keys = make([]string, 0, len(item))
+		for k := range item {
+			if !strings.HasPrefix(k, ".") && !apiReadOnly[k] {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			words = append(words, "="+k+"="+item[k])
+		}
+		if next := items[id] + 1; next < len(reply.Re) {
+			words = append(words, "=place-before="+reply.Re[next][".id"])
+		}
+		ret = append(ret, apiUndo{Words: words, Restores: id})
+	}
+	if c.Verb == "remove" {
+		return ret, -len(ids), nil
+	}
+	return ret, 0, nil
+}
+
+// apiRestore returns the commands restoring the properties of an item changed by "=key=value" arguments: a set of the
+// previous values, and an unset of the properties the item didn't have.
+func apiRestore(path string, id string, item map[string]string, args []string) []apiUndo {
+	var set = []string{path + "/set"}
+	var ret []apiUndo
+	if id != "" {
+		set = append(set, "=.id="+id)
+	}
+	for _, arg := range args {
+		k := strings.SplitN(arg[1:], "=", 2)[0]
+		if v, ok := item[k]; ok {
+			set = append(set, "="+k+"="+v)
+			continue
+		}
+		var unset = []string{path + "/unset", "=value-name=" + k}
+		if id != "" {
+			unset = append(unset, "=numbers="+id)
+		}
+		ret = append(ret, apiUndo{Words: unset})
+	}
+	if len(set) > 2 || id == "" && len(set) > 1 {
+		ret = append(ret, apiUndo{Words: set})
+	}
+	return ret
+}
+
+// rollbackAPI runs the undo commands in reverse order, tracking the new IDs of the restored items.
+func rollbackAPI(api APIRunner, journal []apiUndo) error {
+	var ids = make(map[string]string)
+	var errs []string
+	for idx := len(journal) - 1; idx >= 0; idx-- {
+		var words = make([]string, len(journal[idx].Words))
+		for i, w := range journal[idx].Words {
+			for _, prefix := range []string{"=.id=", "=numbers=", "=place-before="} {
+				if id, ok := ids[strings.TrimPrefix(w, prefix)]; ok && strings.HasPrefix(w, prefix) {
+					w = prefix + id
+				}
+			}
+			words[i] = w
+		}
+
+		reply, err := api.Run(words...)
+		if err != nil {
+			errs = append(errs, err.Error())
+			continue
+		}
+		if journal[idx].Restores != "" {
+			ids[journal[idx].Restores] = reply.Done["ret"]
+		}
+	}
+	if len(errs) > 0 {
+		return errors.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+// pushScript pushes a RouterOS script to the fake router.
+func pushScript(router *fakeRouterOS, script string) error {
+	commands, err := apiCommands(parseRsc(strings.NewReader(script)))
+	if err != nil {
+		return err
+	}
+	client, err := dialAPI(router.Address(), false)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = client.Close() }()
+	if err := client.Login("admin", "secret"); err != nil {
+		return err
+	}
+	return pushMikrotik(client, commands, io.Discard)
+}
+
+// startLoadedRouter starts a fake router loaded with a RouterOS script.
+func startLoadedRouter(t *testing.T, export string) *fakeRouterOS {
+	router, err := startFakeRouterOS("admin", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = router.Close() })
+	router.load(parseRsc(strings.NewReader(export)))
+	return router
+}
+
+const pushChanges = `/ip firewall filter
+remove [find chain=Clients__DMZ comment="ID: 2 - Client-A -> Web1"]
+add chain=Clients__DMZ src-address=10.0.0.10/32 dst-address=192.168.5.9/32 protocol=tcp dst-port=22 action=accept comment="ID: 2 - Client-A -> Web1" place-before=[:pick [find chain=Clients__DMZ comment="ID: 1 - web - Client-Net -> DMZ__Webs"] 0]
+set [find chain=Clients__DMZ comment="ID: 20 - Client-A -> Web2"] action=drop log=yes
+/ip firewall address-list
+add list=DMZ__Webs address=192.168.5.11 comment=Web3
+/ip settings
+set tcp-syncookies=no
+`
+
+func TestPush(t *testing.T) {
+	export, err := os.ReadFile("testdata/branch.rsc")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	router := startLoadedRouter(t, "")
+	if err := pushScript(router, string(export)); err != nil {
+		t.Fatal(err)
+	}
+	loaded := startLoadedRouter(t, string(export))
+	if got, want := router.Export(), loaded.Export(); got != want {
+		t.Fatalf("pushed state differs from the loaded script:\n%s\nwant\n%s", got, want)
+	}
+
+	if err := pushScript(router, pushChanges); err != nil {
+		t.Fatal(err)
+	}
+	got := router.Export()
+	for _, want := range []string{
+		"add action=accept chain=Clients__DMZ comment=\"ID: 2 - Client-A -> Web1\" dst-address=192.168.5.9 dst-port=22 protocol=tcp src-address=10.0.0.10\n" +
+			"add action=accept chain=Clients__DMZ comment=\"ID: 1 - web - Client-Net -> DMZ__Webs\"",
+		"add action=drop chain=Clients__DMZ comment=\"ID: 20 - Client-A -> Web2\" dst-address=192.168.5.10 dst-port=443 log=yes",
+		"add address=192.168.5.11 comment=Web3 list=DMZ__Webs\n",
+		"add tcp-syncookies=no\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("missing %q in\n%s", want, got)
+		}
+	}
+}
+
+func TestPushRollback(t *testing.T) {
+	export, err := os.ReadFile("testdata/branch.rsc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	initial := startLoadedRouter(t, string(export)).Export()
+
+	for _, script := range []string{pushChanges, string(export)} {
+		// The failure is injected in each change in turn, until the push has fewer changes
+		for failAt := 1; ; failAt++ {
+			router := startLoadedRouter(t, string(export))
+			router.FailAt = failAt
+			err := pushScript(router, script)
+			if err == nil {
+				if failAt == 1 {
+					t.Fatal("the push made no change")
+				}
+				break
+			}
+			if !strings.Contains(err.Error(), "failure injected") {
+				t.Fatalf("change %d: got error %v, want the injected failure", failAt, err)
+			}
+			if got := router.Export(); got != initial {
+				t.Fatalf("change %d: state after the rollback differs from the initial one:\n%s", failAt, got)
+			}
+			_ = router.Close()
+		}
+	}
+}
@@ -0,0 +1,346 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	StatusConverted    = "converted"
+	StatusPartial      = "partial"
+	StatusSkipped      = "skipped"
+	StatusNotConverted = "not converted"
+)
+
+var reportStatuses = []string{StatusConverted, StatusPartial, StatusSkipped, StatusNotConverted}
+
+// Report is the conversion report of the policies, in configuration order.
+type Report struct {
+	Policies []PolicyReport
+	Stats    ReportStats
+}
+
+// PolicyReport is the conversion of a policy: its original lines, the addresses and services it expands to, the
+// generated filter rules, and why it was not (entirely) converted.
+type PolicyReport struct {
+	Policy       Policy
+	Lines        []string
+	Sources      []ReportAddress
+	Destinations []ReportAddress
+	Services     []ReportService
+	Rules        []string
+	Status       string
+	Reasons      []string
+	Findings     []string
+}
+
+// ReportAddress is an address book entry with its addresses, and the name of the object of each address.
+type ReportAddress struct {
+	Zone       string
+	Name       string
+	Addresses  []string
+	Objects    []string
+	Provenance Provenance
+}
+
+type ReportService struct {
+	Name       string
+	Entries    string
+	Provenance Provenance
+}
+
+type ReportStats struct {
+	Policies    int
+	Statuses    map[string]int
+	Rules       int
+	ZonePairs   int
+	Findings    map[string]int
+	ListEntries int
+}
+
+// buildReport explains the conversion of each policy with the given zone filter and options. The original lines are
+// looked up in text with the provenance of the policy, which is unknown if the configuration is not a ScreenOS one.
+func buildReport(cfg Config, text string, zone string, opts MikrotikOptions) Report {
+	var rules = make(map[int][]string)
+	var ret = Report{Stats: ReportStats{Statuses: make(map[string]int), Findings: make(map[string]int)}}
+	var pairs = make(map[string]bool)
+	for _, cmd := range parseRsc(strings.NewReader(buildMikrotik(filterPolicies(cfg.Policies, zone), cfg.Objects, cfg.Services, opts))) {
+		switch cmd.Menu {
+		case menuAddressList:
+			ret.Stats.ListEntries++
+		case menuFilter:
+			if m := ruleIDRx.FindStringSubmatch(cmd.Args["comment"]); m != nil {
+				id, _ := strconv.Atoi(m[2])
+				rules[id] = append(rules[id], "add"+rscArgs(cmd, nil))
+				ret.Stats.Rules++
+				pairs[cmd.Args["chain"]] = true
+			}
+		}
+	}
+	ret.Stats.ZonePairs = len(pairs)
+
+	var findings = make(map[int][]string)
+	for _, f := range analyzePolicies(cfg.Policies, cfg.Objects, cfg.Services) {
+		findings[f.Policy] = append(findings[f.Policy], f.String())
+		ret.Stats.Findings[f.Kind]++
+	}
+
+	for idx, p := range cfg.Policies {
+		var r = PolicyReport{Policy: p, Lines: p.Provenance.text(text), Rules: rules[p.ID], Findings: findings[p.ID]}
+		var missing = false
+		for _, src := range p.Sources {
+			a := reportAddress(cfg.Objects, p.From, src, opts)
+			if len(a.Addresses) == 0 {
+				r.Reasons = append(r.Reasons, "source "+p.From+"/"+src+" not found")
+				missing = true
+			}
+			r.Sources = append(r.Sources, a)
+		}
+		for _, dst := range p.Destinations {
+			a := reportAddress(cfg.Objects, p.To, dst, opts)
+			if len(a.Addresses) == 0 {
+				r.Reasons = append(r.Reasons, "destination "+p.To+"/"+dst+" not found")
+				missing = true
+			}
+			r.Destinations = append(r.Destinations, a)
+		}
+		for _, name := range p.Services {
+			var s = ReportService{Name: name, Entries: "any"}
+			if name != "ANY" {
+				s.Entries = serviceListString(cfg.Services[name])
+				s.Provenance = serviceProvenance(cfg.Services[name])
+			}
+			r.Services = append(r.Services, s)
+		}
+
+		switch {
+		case zone != "" && p.From != zone && p.To != zone:
+			r.Status = StatusSkipped
+			r.Reasons = []string{"neither from nor to zone " + zone}
+		case p.Disabled:
+			r.Status = StatusSkipped
+			r.Reasons = []string{"disabled"}
+		case p.IsZonePolicy():
+			r.Status = StatusSkipped
+			r.Reasons = []string{"deny of any to any, left to the default deny rule"}
+			for _, q := range cfg.Policies[idx+1:] {
+				if q.From == p.From && q.To == p.To && !q.Disabled {
+					r.Reasons = append(r.Reasons, fmt.Sprintf("the later policies of %s -> %s (like %d) are not denied by it on RouterOS",
+						p.From, p.To, q.ID))
+					break
+				}
+			}
+		case len(r.Rules) == 0:
+			r.Status = StatusNotConverted
+			if !missing {
+				r.Reasons = append(r.Reasons, "no rule generated")
+			}
+		default:
+			r.Status = StatusConverted
+			if missing {
+				r.Status = StatusPartial
+			}
+			if p.NAT != "" {
+				r.Status = StatusPartial
+				r.Reasons = append(r.Reasons, p.NAT+" not converted")
+			}
+			if p.Application != "" {
+				r.Status = StatusPartial
+				r.Reasons = append(r.Reasons, "application "+p.Application+" not converted")
+			}
+			if p.LogInit {
+				r.Reasons = append(r.Reasons, "log at session init converted as log of the first packet")
+			}
+			if p.Action == ActionTunnel {
+				r.Reasons = append(r.Reasons, "tunnel converted as accept, the traffic is encrypted by the IPsec policy of VPN "+p.VPN)
+			}
+		}
+
+		ret.Policies = append(ret.Policies, r)
+		ret.Stats.Policies++
+		ret.Stats.Statuses[r.Status]++
+	}
+	return ret
+}
+
+func reportAddress(objects Objects, zone string, name string, opts MikrotikOptions) ReportAddress {
+	names, lookup := lookupAddresses(objects, zone, name, opts)
+	var ret = ReportAddress{Zone: zone, Name: name, Objects: names}
+	if obj, ok := objects[zone][name]; ok {
+		ret.Provenance = obj.Provenance
+	}
+	for _, l := range lookup {
+		ret.Addresses = append(ret.Addresses, l.String())
+	}
+	return ret
+}
+
+// addressSummary formats the addresses of an entry with the object of each one, like "10.0.0.1/32 (Web1)".
+func (a ReportAddress) addressSummary() string {
+	if len(a.Addresses) == 0 {
+		return "not found"
+	}
+	var ret = make([]string, len(a.Addresses))
+	for idx, address := range a.Addresses {
+		ret[idx] = address
+		if idx < len(a.Objects) && a.Objects[idx] != "" && a.Objects[idx] != a.Name {
+			ret[idx] += " (" + a.Objects[idx] + ")"
+		}
+	}
+	return strings.Join(ret, ", ")
+}
+
+func (s ReportStats) findingKinds() []string {
+	var ret []string
+	for kind := range s.Findings {
+		ret = append(ret, kind)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// Markdown formats the report as a Markdown document.
+func (r Report) Markdown() string {
+	var ret strings.Builder
+	ret.WriteString("# Conversion report\n\n")
+	ret.WriteString("| Statistic | Count |\n|---|---|\n")
+	for _, row := range r.Stats.rows() {
+		ret.WriteString("| " + markdownEscape(row[0]) + " | " + row[1] + " |\n")
+	}
+
+	for _, p := range r.Policies {
+		ret.WriteString("\n## " + markdownEscape(policyTitle(p.Policy)) + "\n\n")
+		ret.WriteString("**Status:** " + p.Status + "\n")
+		for _, reason := range p.Reasons {
+			ret.WriteString("- " + markdownEscape(reason) + "\n")
+		}
+		for _, finding := range p.Findings {
+			ret.WriteString("- " + markdownEscape(finding) + "\n")
+		}
+
+		ret.WriteString("\n### ScreenOS" + markdownEscape(p.Policy.Provenance.suffix()) + "\n\n")
+		writeMarkdownBlock(&ret, p.Lines, "not available")
+		ret.WriteString("\n### Parsed\n\n")
+		writeMarkdownBlock(&ret, []string{p.Policy.String()}, "")
+
+		ret.WriteString("\n### Objects\n\n")
+		for _, list := range []struct {
+			title     string
+			addresses []ReportAddress
+		}{{"Source", p.Sources}, {"Destination", p.Destinations}} {
+			for _, a := range list.addresses {
+				ret.WriteString("- " + markdownEscape(list.title+" "+a.Zone+"/"+a.Name+a.Provenance.suffix()+": "+
+					a.addressSummary()) + "\n")
+			}
+		}
+		for _, s := range p.Services {
+			ret.WriteString("- " + markdownEscape("Service "+s.Name+s.Provenance.suffix()+": "+s.Entries) + "\n")
+		}
+
+		ret.WriteString("\n### RouterOS\n\n")
+		writeMarkdownBlock(&ret, p.Rules, "no rules")
+	}
+	return ret.String()
+}
+
+// markdownEscape escapes the pipes and the line breaks of a text, which would otherwise end a table cell, a heading or
+// a list item.
+func markdownEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "|", `\|`, "\r\n", "<br>", "\n", "<br>", "\r", "<br>").Replace(s)
+}
+
+func writeMarkdownBlock(ret *strings.Builder, lines []string, empty string) {
+	if len(lines) == 0 {
+		ret.WriteString("_" + empty + "_\n")
+		return
+	}
+	ret.WriteString("```\n" + strings.Join(lines, "\n") + "\n```\n")
+}
+
+// HTML formats the report as a standalone HTML page.
+func (r Report) HTML() string {
+	var ret strings.Builder
+	ret.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>Conversion report</title>\n")
+	ret.WriteString("<style>\nbody { font-family: sans-serif; }\ntable { border-collapse: collapse; }\n" +
+		"td, th { border: 1px solid #999; padding: 2px 8px; text-align: left; }\npre { background: #eee; padding: 4px; }\n" +
+		".converted { color: green; }\n.partial { color: darkorange; }\n.skipped { color: gray; }\n" +
+		".not-converted { color: red; }\n</style>\n</head>\n<body>\n")
+	ret.WriteString("<h1>Conversion report</h1>\n<table>\n<tr><th>Statistic</th><th>Count</th></tr>\n")
+	for _, row := range r.Stats.rows() {
+		ret.WriteString("<tr><td>" + html.EscapeString(row[0]) + "</td><td>" + row[1] + "</td></tr>\n")
+	}
+	ret.WriteString("</table>\n")
+
+	for _, p := range r.Policies {
+		ret.WriteString(fmt.Sprintf("<h2 id=\"policy-%d\">%s</h2>\n", p.Policy.ID, html.EscapeString(policyTitle(p.Policy))))
+		ret.WriteString("<p><b>Status:</b> <span class=\"" + strings.ReplaceAll(p.Status, " ", "-") + "\">" + p.Status + "</span></p>\n")
+		if len(p.Reasons) > 0 || len(p.Findings) > 0 {
+			ret.WriteString("<ul>\n")
+			for _, reason := range append(append([]string{}, p.Reasons...), p.Findings...) {
+				ret.WriteString("<li>" + html.EscapeString(reason) + "</li>\n")
+			}
+			ret.WriteString("</ul>\n")
+		}
+
+		ret.WriteString("<h3>ScreenOS" + html.EscapeString(p.Policy.Provenance.suffix()) + "</h3>\n")
+		writeHTMLBlock(&ret, p.Lines, "not available")
+		ret.WriteString("<h3>Parsed</h3>\n")
+		writeHTMLBlock(&ret, []string{p.Policy.String()}, "")
+
+		ret.WriteString("<h3>Objects</h3>\n<table>\n")
+		for _, list := range []struct {
+			title     string
+			addresses []ReportAddress
+		}{{"Source", p.Sources}, {"Destination", p.Destinations}} {
+			for _, a := range list.addresses {
+				ret.WriteString("<tr><td>" + list.title + "</td><td>" + html.EscapeString(a.Zone+"/"+a.Name) + "</td><td>" +
+					html.EscapeString(a.addressSummary()) + "</td><td>" + html.EscapeString(a.Provenance.String()) +
+					"</td></tr>\n")
+			}
+		}
+		for _, s := range p.Services {
+			ret.WriteString("<tr><td>Service</td><td>" + html.EscapeString(s.Name) + "</td><td>" +
+				html.EscapeString(s.Entries) + "</td><td>" + html.EscapeString(s.Provenance.String()) + "</td></tr>\n")
+		}
+		ret.WriteString("</table>\n")
+
+		ret.WriteString("<h3>RouterOS</h3>\n")
+		writeHTMLBlock(&ret, p.Rules, "no rules")
+	}
+	ret.WriteString("</body>\n</html>\n")
+	return ret.String()
+}
+
+func writeHTMLBlock(ret *strings.Builder, lines []string, empty string) {
+	if len(lines) == 0 {
+		ret.WriteString("<p><i>" + empty + "</i></p>\n")
+		return
+	}
+	ret.WriteString("<pre>" + html.EscapeString(strings.Join(lines, "\n")) + "</pre>\n")
+}
+
+func policyTitle(p Policy) string {
+	var ret = fmt.Sprintf("Policy %d", p.ID)
+	if p.Name != "" {
+		ret += " \"" + p.Name + "\""
+	}
+	return ret + ": " + p.From + " -> " + p.To
+}
+
+// rows returns the statistics as name and count pairs.
+func (s ReportStats) rows() [][2]string {
+	var ret = [][2]string{{"Policies", strconv.Itoa(s.Policies)}}
+	for _, status := range reportStatuses {
+		ret = append(ret, [2]string{"Policies " + status, strconv.Itoa(s.Statuses[status])})
+	}
+	ret = append(ret, [2]string{"Filter rules", strconv.Itoa(s.Rules)},
+		[2]string{"Zone pair chains", strconv.Itoa(s.ZonePairs)},
+		[2]string{"Address list entries", strconv.Itoa(s.ListEntries)})
+	for _, kind := range s.findingKinds() {
+		ret = append(ret, [2]string{"Findings " + kind, strconv.Itoa(s.Findings[kind])})
+	}
+	return ret
+}
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReportMarkdownEscape(t *testing.T) {
+	const config = `set service "web|alt" protocol tcp src-port 0-65535 dst-port 8080-8080
+set policy id 1 from "Trust" to "DMZ"  "h1" "s1" "web|alt" permit
+`
+	cfg := parse(strings.NewReader(syncAddresses+config), "test.cfg")
+	cfg.Policies[0].Name = "web\nbackup"
+	got := buildReport(cfg, "", "", MikrotikOptions{}).Markdown()
+	for _, want := range []string{
+		"\n## Policy 1 \"web<br>backup\": Trust -> DMZ\n",
+		"\n- Service web\\|alt (test.cfg:3): ",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("got\n%s\nwant %q", got, want)
+		}
+	}
+}
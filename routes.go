@@ -0,0 +1,26 @@
+package main
+
+import (
+	"net"
+)
+
+// DefaultVRouter is the virtual router used for routes outside any "set vrouter" block. It maps to the RouterOS main
+// routing table.
+const DefaultVRouter = "trust-vr"
+
+type Route struct {
+	VRouter     string
+	Prefix      *net.IPNet
+	Interface   string
+	Gateway     net.IP
+	NextVRouter string
+	Preference  int
+	Metric      int
+	Tag         int
+	Description string
+	Permanent   bool
+}
+
+func (r *Route) IsIPv6() bool {
+	return r.Prefix.IP.To4() == nil
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bufio"
+	"io"
+	"strings"
+)
+
+// RscCommand is a command of a RouterOS script or export, like "add chain=forward action=accept" in the
+// "/ip firewall filter" menu.
+type RscCommand struct {
+	Menu string
+	Verb string
+
+	// Find is the item selector of set/remove commands, e.g. "[find]" or "[find where name=x]"
+	Find string
+
+	Args map[string]string
+	Keys []string
+	Line int
+}
+
+var rscVerbs = map[string]bool{"add": true, "set": true, "remove": true, "enable": true, "disable": true}
+
+// parseRsc reads a RouterOS script, as emitted by the converter or by "/export". Comments are skipped and lines ending
+// with a backslash are joined to the next one.
+func parseRsc(reader io.Reader) []RscCommand {
+	var ret []RscCommand
+	var menu = ""
+	var pending = ""
+	var pendingLine = 0
+	var lineNumber = 0
+
+	var scanner = bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		lineNumber++
+		var line = strings.TrimSpace(scanner.Text())
+		if pending == "" {
+			pendingLine = lineNumber
+		}
+		if strings.HasSuffix(line, "\\") {
+			pending += strings.TrimSuffix(line, "\\")
+			continue
+		}
+		line = pending + line
+		pending = ""
+
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		tokens := rscTokens(line)
+		if strings.HasPrefix(line, "/") {
+			// The menu can be followed by a command on the same line
+			var idx = 0
+			for idx < len(tokens) && !rscVerbs[tokens[idx]] {
+				idx++
+			}
+			menu = strings.Join(tokens[:idx], " ")
+			tokens = tokens[idx:]
+			if len(tokens) == 0 {
+				continue
+			}
+		}
+
+		var cmd = RscCommand{Menu: menu, Verb: tokens[0], Args: make(map[string]string), Line: pendingLine}
+		var find []string
+		for _, token := range tokens[1:] {
+			if eq := strings.Index(token, "="); eq > 0 && !strings.HasPrefix(token, "[") {
+				cmd.Args[token[:eq]] = rscUnquote(token[eq+1:])
+				cmd.Keys = append(cmd.Keys, token[:eq])
+			} else {
+				find = append(find, token)
+			}
+		}
+		cmd.Find = strings.Join(find, " ")
+		ret = append(ret, cmd)
+	}
+	return ret
+}
+
+// rscTokens splits a RouterOS command line on spaces, keeping quoted strings and [ ] expressions together.
+func rscTokens(line string) []string {
+	var ret []string
+	var current strings.Builder
+	var quoted = false
+	var brackets = 0
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quoted && c == '\\' && i+1 < len(line):
+			current.WriteByte(c)
+			current.WriteByte(line[i+1])
+			i++
+			continue
+		case c == '"':
+			quoted = !quoted
+		case !quoted && c == '[':
+			brackets++
+		case !quoted && c == ']' && brackets > 0:
+			brackets--
+		case !quoted && brackets == 0 && (c == ' ' || c == '\t'):
+			if current.Len() > 0 {
+				ret = append(ret, current.String())
+				current.Reset()
+			}
+			continue
+		}
+		current.WriteByte(c)
+	}
+	if current.Len() > 0 {
+		ret = append(ret, current.String())
+	}
+	return ret
+}
+
+// rscUnquote removes the quotes and the escapes from a RouterOS value.
+func rscUnquote(value string) string {
+	if len(value) < 2 || value[0] != '"' || value[len(value)-1] != '"' {
+		return value
+	}
+	value = value[1 : len(value)-1]
+
+	var ret strings.Builder
+	for i := 0; i < len(value); i++ {
+		if value[i] == '\\' && i+1 < len(value) {
+			i++
+			switch value[i] {
+			case 'n':
+				ret.WriteByte('\n')
+			case 't':
+				ret.WriteByte('\t')
+			case '_':
+				ret.WriteByte(' ')
+			default:
+				ret.WriteByte(value[i])
+			}
+			continue
+		}
+		ret.WriteByte(value[i])
+	}
+	return ret.String()
+}
+
+// rscQuote quotes a RouterOS value, if needed.
+func rscQuote(value string) string {
+	if value != "" && !strings.ContainsAny(value, " \t\"\\$;[]{}=?#") {
+		return value
+	}
+	var r = strings.NewReplacer("\\", "\\\\", "\"", "\\\"", "$", "\\$", "?", "\\?", "\n", "\\n", "\t", "\\t")
+	return "\"" + r.Replace(value) + "\""
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"sort"
+)
+
+// Screen holds the attack protection ("screen") settings of a zone. Thresholds are 0 when not set, so that the
+// ScreenOS defaults are used.
+type Screen struct {
+	SynFlood                     bool
+	SynFloodAttackThreshold      int
+	SynFloodSourceThreshold      int
+	SynFloodDestinationThreshold int
+
+	ICMPFlood          bool
+	ICMPFloodThreshold int
+	UDPFlood           bool
+	UDPFloodThreshold  int
+
+	PortScan bool
+	// PortScanThreshold is in microseconds
+	PortScanThreshold int
+
+	IPSpoofing bool
+	TearDrop   bool
+	Land       bool
+	PingDeath  bool
+
+	SessionLimitSource      int
+	SessionLimitDestination int
+
+	Unsupported []string
+}
+
+type Screens map[string]*Screen
+
+// Get returns the screen settings of the given zone, creating them if they don't exist yet.
+func (s Screens) Get(zone string) *Screen {
+	if _, ok := s[zone]; !ok {
+		s[zone] = &Screen{}
+	}
+	return s[zone]
+}
+
+func (s Screens) Zones() []string {
+	var ret = make([]string, 0, len(s))
+	for zone := range s {
+		ret = append(ret, zone)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// Set applies a "set zone ... screen" option, with its optional parameter and value. It returns false if the option is
+// not supported.
+func (s *Screen) Set(option string, param string, value int) bool {
+	switch {
+	case option == "syn-flood" && param == "":
+		s.SynFlood = true
+	case option == "syn-flood" && param == "attack-threshold":
+		s.SynFloodAttackThreshold = value
+	case option == "syn-flood" && param == "source-threshold":
+		s.SynFloodSourceThreshold = value
+	case option == "syn-flood" && param == "destination-threshold":
+		s.SynFloodDestinationThreshold = value
+	case option == "syn-flood" && (param == "alarm-threshold" || param == "timeout" || param == "queue-size"):
+		// SYN proxy tuning, replaced by RouterOS SYN cookies
+	case option == "icmp-flood" && param == "":
+		s.ICMPFlood = true
+	case option == "icmp-flood" && param == "threshold":
+		s.ICMPFloodThreshold = value
+	case option == "udp-flood" && param == "":
+		s.UDPFlood = true
+	case option == "udp-flood" && param == "threshold":
+		s.UDPFloodThreshold = value
+	case option == "port-scan" && param == "":
+		s.PortScan = true
+	case option == "port-scan" && param == "threshold":
+		s.PortScanThreshold = value
+	case option == "ip-spoofing" && param == "":
+		s.IPSpoofing = true
+	case option == "tear-drop" && param == "":
+		s.TearDrop = true
+	case option == "land" && param == "":
+		s.Land = true
+	case option == "ping-death" && param == "":
+		s.PingDeath = true
+	case option == "limit-session" && param == "source-ip-based":
+		s.SessionLimitSource = value
+		if value == 0 {
+			s.SessionLimitSource = 128
+		}
+	case option == "limit-session" && param == "destination-ip-based":
+		s.SessionLimitDestination = value
+		if value == 0 {
+			s.SessionLimitDestination = 128
+		}
+	default:
+		return false
+	}
+	return true
+}
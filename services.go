@@ -1,12 +1,19 @@
 package main
 
+import (
+	"sort"
+)
+
 type Service struct {
-	Protocol     string
-	IcmpType     int
-	SrcPortStart int
-	SrcPortEnd   int
-	DstPortStart int
-	DstPortEnd   int
+	Protocol     string `json:"protocol" yaml:"protocol"`
+	IcmpType     int    `json:"icmp_type,omitempty" yaml:"icmp_type,omitempty"`
+	SrcPortStart int    `json:"src_port_start" yaml:"src_port_start"`
+	SrcPortEnd   int    `json:"src_port_end" yaml:"src_port_end"`
+	DstPortStart int    `json:"dst_port_start" yaml:"dst_port_start"`
+	DstPortEnd   int    `json:"dst_port_end" yaml:"dst_port_end"`
+
+	// Provenance is not part of the exported model
+	Provenance Provenance `json:"-" yaml:"-"`
 }
 
 type Services map[string]ServiceList
@@ -30,5 +37,6 @@ func (sl ServiceList) AllProtocols() []string {
 	for proto := range protos {
 		ret = append(ret, proto)
 	}
+	sort.Strings(ret)
 	return ret
 }
@@ -0,0 +1,360 @@
+package main
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const (
+	menuAddressList = "/ip firewall address-list"
+	menuFilter      = "/ip firewall filter"
+)
+
+// ruleIDRx matches the comment of a converted policy rule, optionally with the ownership tag (see tagMikrotik)
+var ruleIDRx = regexp.MustCompile(`^(\[[^]]*\] )?ID: ([0-9]+)( |$)`)
+
+// ruleGroup is a sequence of filter rules managed together: the rules of a policy in its chain, or a single rule
+// without policy ID (like the forward chain jumps).
+type ruleGroup struct {
+	Key   string
+	Rules []RscCommand
+}
+
+// syncMikrotik returns the commands which transform the address lists and filter rules of a RouterOS export (current)
+// into the converted ones (desired). Filter rules are matched by the policy ID in their comment; other rules and
+// address lists are only touched if they are managed by the converter.
+func syncMikrotik(current []RscCommand, desired []RscCommand) string {
+	var curFilter = commandsOf(current, menuFilter)
+	var desFilter = commandsOf(desired, menuFilter)
+
+	// The chains of the converted policies, in the router and in the new configuration
+	var policyChains = make(map[string]bool)
+	for _, cmds := range [][]RscCommand{curFilter, desFilter} {
+		for _, cmd := range cmds {
+			if ruleIDRx.MatchString(cmd.Args["comment"]) {
+				policyChains[cmd.Args["chain"]] = true
+			}
+		}
+	}
+
+	var desGroups = groupRules(desFilter)
+	var desKeys = make(map[string]bool, len(desGroups))
+	for _, g := range desGroups {
+		desKeys[g.Key] = true
+	}
+
+	// Rules without ID are ours only if they are converted too, or if they jump to a policy chain
+	var curGroups []ruleGroup
+	for _, g := range groupRules(curFilter) {
+		rule := g.Rules[0]
+		if ruleIDRx.MatchString(rule.Args["comment"]) || desKeys[g.Key] ||
+			(rule.Args["action"] == "jump" && policyChains[rule.Args["jump-target"]]) {
+			curGroups = append(curGroups, g)
+		}
+	}
+	var curByKey = make(map[string]ruleGroup, len(curGroups))
+	for _, g := range curGroups {
+		curByKey[g.Key] = g
+	}
+
+	var comments = make(map[string]int)
+	for _, cmd := range curFilter {
+		comments[cmd.Args["comment"]]++
+	}
+
+	// Groups already on the router stay in place, possibly updated with set; the others are (re)added before the next
+	// group staying in place in the same chain
+	var stays = make([]bool, len(desGroups))
+	var sets = make([][]string, len(desGroups))
+	for idx, g := range desGroups {
+		if cur, ok := curByKey[g.Key]; ok {
+			sets[idx], stays[idx] = ruleGroupUpdate(cur.Rules, g.Rules, comments)
+		}
+	}
+	keepOrder(desGroups, curGroups, stays)
+
+	var filter strings.Builder
+	for idx, g := range desGroups {
+		if cur, ok := curByKey[g.Key]; ok && !stays[idx] {
+			filter.WriteString(ruleGroupRemove(cur.Rules))
+		}
+	}
+	for _, g := range curGroups {
+		if !desKeys[g.Key] {
+			filter.WriteString(ruleGroupRemove(g.Rules))
+		}
+	}
+	for idx, g := range desGroups {
+		if stays[idx] {
+			filter.WriteString(strings.Join(sets[idx], ""))
+			continue
+		}
+
+		var placeBefore = ""
+		for next := idx + 1; next < len(desGroups); next++ {
+			if stays[next] && desGroups[next].Rules[0].Args["chain"] == g.Rules[0].Args["chain"] {
+				placeBefore = " place-before=[:pick [find " + rscFind(curByKey[desGroups[next].Key].Rules[0]) + "] 0]"
+				break
+			}
+		}
+		for _, rule := range g.Rules {
+			filter.WriteString("add" + rscArgs(rule, nil) + placeBefore + "\n")
+		}
+	}
+
+	var lists, stale = syncAddressLists(commandsOf(current, menuAddressList), commandsOf(desired, menuAddressList), curFilter)
+
+	var ret strings.Builder
+	writeSection(&ret, menuAddressList, lists)
+	writeSection(&ret, menuFilter, filter.String())
+	writeSection(&ret, menuAddressList, stale)
+	return ret.String()
+}
+
+// keepOrder clears the stays flag of the groups which moved: in each chain, the largest set of groups staying in
+// place whose order on the router is the desired one is kept, and the other groups are moved by re-adding them.
+func keepOrder(desired []ruleGroup, current []ruleGroup, stays []bool) {
+	var curPos = make(map[string]int, len(current))
+	for idx, g := range current {
+		curPos[g.Key] = idx
+	}
+
+	var chains = make(map[string][]int)
+	var chainNames []string
+	for idx, g := range desired {
+		if !stays[idx] {
+			continue
+		}
+		chain := g.Rules[0].Args["chain"]
+		if _, ok := chains[chain]; !ok {
+			chainNames = append(chainNames, chain)
+		}
+		chains[chain] = append(chains[chain], idx)
+	}
+
+	for _, chain := range chainNames {
+		var groups = chains[chain]
+		var positions = make([]int, len(groups))
+		for idx, desIdx := range groups {
+			positions[idx] = curPos[desired[desIdx].Key]
+		}
+		var inOrder = longestIncreasing(positions)
+		for idx, desIdx := range groups {
+			stays[desIdx] = inOrder[idx]
+		}
+	}
+}
+
+// longestIncreasing returns which values belong to a longest increasing subsequence; among the longest ones, the one
+// ending with the smallest values. Both sync and diff use it, so they agree on which rules and policies moved.
+func longestIncreasing(values []int) []bool {
+	// tails[n] is the index of the smallest value ending an increasing subsequence of length n+1
+	var tails []int
+	var prev = make([]int, len(values))
+	for idx, v := range values {
+		n := sort.Search(len(tails), func(i int) bool { return values[tails[i]] >= v })
+		prev[idx] = -1
+		if n > 0 {
+			prev[idx] = tails[n-1]
+		}
+		if n == len(tails) {
+			tails = append(tails, idx)
+		} else {
+			tails[n] = idx
+		}
+	}
+
+	var ret = make([]bool, len(values))
+	if len(tails) > 0 {
+		for idx := tails[len(tails)-1]; idx >= 0; idx = prev[idx] {
+			ret[idx] = true
+		}
+	}
+	return ret
+}
+
+// syncAddressLists returns the commands adding and updating the address list entries, and the commands removing the
+// stale ones. Only the lists of the new configuration and the lists used by converted rules are managed.
+func syncAddressLists(current []RscCommand, desired []RscCommand, currentFilter []RscCommand) (string, string) {
+	var managed = make(map[string]bool)
+	for _, cmd := range desired {
+		managed[cmd.Args["list"]] = true
+	}
+	for _, cmd := range currentFilter {
+		if ruleIDRx.MatchString(cmd.Args["comment"]) {
+			managed[cmd.Args["src-address-list"]] = true
+			managed[cmd.Args["dst-address-list"]] = true
+		}
+	}
+
+	var existing = make(map[string]RscCommand)
+	for _, cmd := range current {
+		if managed[cmd.Args["list"]] {
+			existing[addressListKey(cmd)] = cmd
+		}
+	}
+
+	var lists strings.Builder
+	var wanted = make(map[string]bool)
+	for _, cmd := range desired {
+		key := addressListKey(cmd)
+		wanted[key] = true
+		if cur, ok := existing[key]; !ok {
+			lists.WriteString("add" + rscArgs(cmd, nil) + "\n")
+		} else if cur.Args["comment"] != cmd.Args["comment"] {
+			lists.WriteString("set [find " + addressListFind(cur) + "] comment=" + rscQuote(cmd.Args["comment"]) + "\n")
+		}
+	}
+
+	var stale []string
+	for key, cmd := range existing {
+		if !wanted[key] {
+			stale = append(stale, "remove [find "+addressListFind(cmd)+"]\n")
+		}
+	}
+	sort.Strings(stale)
+	return lists.String(), strings.Join(stale, "")
+}
+
+func addressListKey(cmd RscCommand) string {
+	return cmd.Args["list"] + " " + normalizeRscValue("address", cmd.Args["address"])
+}
+
+func addressListFind(cmd RscCommand) string {
+	return "list=" + rscQuote(cmd.Args["list"]) + " address=" + rscQuote(cmd.Args["address"])
+}
+
+func commandsOf(commands []RscCommand, menu string) []RscCommand {
+	var ret []RscCommand
+	for _, cmd := range commands {
+		if cmd.Menu == menu && cmd.Verb == "add" {
+			ret = append(ret, cmd)
+		}
+	}
+	return ret
+}
+
+// groupRules groups the consecutive rules of each policy.
+func groupRules(rules []RscCommand) []ruleGroup {
+	var ret []ruleGroup
+	for _, rule := range rules {
+		var key = rule.Args["chain"] + normalizedRscArgs(rule)
+		var m = ruleIDRx.FindStringSubmatch(rule.Args["comment"])
+		if m != nil {
+			key = rule.Args["chain"] + " ID " + m[2]
+		}
+
+		if m != nil && len(ret) > 0 && ret[len(ret)-1].Key == key {
+			ret[len(ret)-1].Rules = append(ret[len(ret)-1].Rules, rule)
+		} else {
+			ret = append(ret, ruleGroup{Key: key, Rules: []RscCommand{rule}})
+		}
+	}
+	return ret
+}
+
+// ruleGroupUpdate returns the set commands which update the current rules of a group to the desired ones. It returns
+// false if the group must be replaced instead: when the number of rules changes, a property must be removed, or the
+// rule can't be found by its comment.
+func ruleGroupUpdate(current []RscCommand, desired []RscCommand, comments map[string]int) ([]string, bool) {
+	if len(current) != len(desired) {
+		return nil, false
+	}
+
+	var ret []string
+	for idx := range desired {
+		cur := normalizedArgs(current[idx])
+		des := normalizedArgs(desired[idx])
+		var changed []string
+		for _, k := range desired[idx].Keys {
+			if cur[k] != des[k] {
+				changed = append(changed, k)
+			}
+		}
+		for k := range cur {
+			if _, ok := des[k]; !ok {
+				return nil, false
+			}
+		}
+		if len(changed) == 0 {
+			continue
+		}
+		if comments[current[idx].Args["comment"]] != 1 {
+			return nil, false
+		}
+		var set = RscCommand{Args: desired[idx].Args, Keys: changed}
+		ret = append(ret, "set [find "+rscFind(current[idx])+"]"+rscArgs(set, nil)+"\n")
+	}
+	return ret, true
+}
+
+// ruleGroupRemove removes the rules of a group from the router.
+func ruleGroupRemove(rules []RscCommand) string {
+	var ret strings.Builder
+	var seen = make(map[string]bool)
+	for _, rule := range rules {
+		find := rscFind(rule)
+		if !seen[find] {
+			seen[find] = true
+			ret.WriteString("remove [find " + find + "]\n")
+		}
+	}
+	return ret.String()
+}
+
+// rscFind returns a find expression for the rule: its chain and comment, or its matchers if it has no comment.
+func rscFind(rule RscCommand) string {
+	if rule.Args["comment"] != "" {
+		return "chain=" + rscQuote(rule.Args["chain"]) + " comment=" + rscQuote(rule.Args["comment"])
+	}
+	return strings.TrimSpace(rscArgs(rule, map[string]bool{"log": true, "log-prefix": true, "place-before": true}))
+}
+
+// rscArgs formats the arguments of a command, in their original order.
+func rscArgs(cmd RscCommand, skip map[string]bool) string {
+	var ret strings.Builder
+	for _, k := range cmd.Keys {
+		if !skip[k] {
+			ret.WriteString(" " + k + "=" + rscQuote(cmd.Args[k]))
+		}
+	}
+	return ret.String()
+}
+
+// normalizedRscArgs formats the normalized arguments of a command, sorted by name.
+func normalizedRscArgs(cmd RscCommand) string {
+	var args = normalizedArgs(cmd)
+	var keys = make([]string, 0, len(args))
+	for k := range args {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var ret strings.Builder
+	for _, k := range keys {
+		ret.WriteString(" " + k + "=" + args[k])
+	}
+	return ret.String()
+}
+
+// normalizedArgs returns the arguments of a command as printed by /export, which omits default values.
+func normalizedArgs(cmd RscCommand) map[string]string {
+	var ret = make(map[string]string, len(cmd.Args))
+	for k, v := range cmd.Args {
+		if (k == "log" || k == "disabled") && v == "no" {
+			continue
+		}
+		ret[k] = normalizeRscValue(k, v)
+	}
+	return ret
+}
+
+// normalizeRscValue removes the /32 suffix from host addresses, as RouterOS does.
+func normalizeRscValue(key string, value string) string {
+	switch key {
+	case "address", "src-address", "dst-address":
+		return strings.TrimSuffix(value, "/32")
+	}
+	return value
+}
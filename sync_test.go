@@ -0,0 +1,68 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const syncAddresses = `set address "Trust" "h1" 10.0.0.1 255.255.255.255
+set address "DMZ" "s1" 10.1.0.1 255.255.255.255
+`
+
+const (
+	syncPolicy1 = `set policy id 1 from "Trust" to "DMZ"  "h1" "s1" "HTTP" deny` + "\n"
+	syncPolicy2 = `set policy id 2 from "Trust" to "DMZ"  "Any" "Any" "HTTP" permit` + "\n"
+	syncPolicy3 = `set policy id 3 from "Trust" to "DMZ"  "Any" "Any" "HTTPS" permit` + "\n"
+)
+
+// convertedRules returns the filter rules and address lists converted from a ScreenOS configuration.
+func convertedRules(config string) []RscCommand {
+	cfg := parse(strings.NewReader(config), "test.cfg")
+	return parseRsc(strings.NewReader(buildMikrotik(cfg.Policies, cfg.Objects, cfg.Services, MikrotikOptions{})))
+}
+
+func TestSyncMikrotik(t *testing.T) {
+	var current = convertedRules(syncAddresses + syncPolicy1 + syncPolicy2 + syncPolicy3)
+	for _, tc := range []struct {
+		name    string
+		desired string
+		want    string
+	}{
+		{"unchanged", syncPolicy1 + syncPolicy2 + syncPolicy3, ""},
+		{"swapped", syncPolicy2 + syncPolicy1 + syncPolicy3, `/ip firewall filter
+remove [find chain=Trust__DMZ comment="ID: 2 - Any -> Any"]
+add chain=Trust__DMZ protocol=tcp dst-port=80 action=accept comment="ID: 2 - Any -> Any" place-before=[:pick [find chain=Trust__DMZ comment="ID: 1 - h1 -> s1"] 0]
+`},
+		{"moved to the end", syncPolicy2 + syncPolicy3 + syncPolicy1, `/ip firewall filter
+remove [find chain=Trust__DMZ comment="ID: 1 - h1 -> s1"]
+add chain=Trust__DMZ src-address=10.0.0.1/32 dst-address=10.1.0.1/32 protocol=tcp dst-port=80 action=drop comment="ID: 1 - h1 -> s1"
+`},
+		{"removed", syncPolicy1 + syncPolicy3, `/ip firewall filter
+remove [find chain=Trust__DMZ comment="ID: 2 - Any -> Any"]
+`},
+	} {
+		got := syncMikrotik(current, convertedRules(syncAddresses+tc.desired))
+		if strings.TrimSpace(got) != strings.TrimSpace(tc.want) {
+			t.Errorf("%s: got\n%s\nwant\n%s", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestLongestIncreasing(t *testing.T) {
+	for _, tc := range []struct {
+		values []int
+		want   []bool
+	}{
+		{nil, []bool{}},
+		{[]int{0, 1, 2}, []bool{true, true, true}},
+		{[]int{1, 0, 2}, []bool{false, true, true}},
+		{[]int{1, 2, 0}, []bool{true, true, false}},
+		{[]int{3, 0, 1, 2}, []bool{false, true, true, true}},
+		{[]int{2, 3, 0, 1}, []bool{false, false, true, true}},
+	} {
+		if got := longestIncreasing(tc.values); !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("%v: got %v, want %v", tc.values, got, tc.want)
+		}
+	}
+}
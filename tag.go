@@ -0,0 +1,73 @@
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+// untaggedMenus are the menus whose items have no comment, so they can't carry the ownership tag.
+var untaggedMenus = map[string]bool{"/ip ipsec profile": true, "/ip ipsec proposal": true, "/ip dhcp-relay": true}
+
+var commentArgRx = regexp.MustCompile(` comment=("(?:[^"\\]|\\.)*"|[^ ]+)`)
+
+// tagMikrotik prefixes the comment of every item added by the script with "[tag] ", to recognize the converted items
+// on the router. With cleanup, the script starts with the removal of the items tagged by a previous run, in reverse
+// menu order so that items are removed before the items they reference. Items without comment are removed by name.
+func tagMikrotik(rsc string, tag string, cleanup bool) string {
+	if tag == "" {
+		return rsc
+	}
+
+	var prefix = "[" + tag + "] "
+	var menus []string
+	var seen = make(map[string]bool)
+	var names = make(map[string][]string)
+	var menu = ""
+	var lines = strings.Split(rsc, "\n")
+	for idx, line := range lines {
+		if strings.HasPrefix(line, "/") {
+			menu = line
+			continue
+		}
+		if !strings.HasPrefix(line, "add ") {
+			continue
+		}
+
+		if !seen[menu] {
+			seen[menu] = true
+			menus = append(menus, menu)
+		}
+		if untaggedMenus[menu] {
+			for _, token := range rscTokens(line) {
+				if strings.HasPrefix(token, "name=") {
+					names[menu] = append(names[menu], token)
+				}
+			}
+			continue
+		}
+		if m := commentArgRx.FindStringSubmatchIndex(line); m != nil {
+			lines[idx] = line[:m[2]] + rscQuote(prefix+rscUnquote(line[m[2]:m[3]])) + line[m[3]:]
+		} else {
+			lines[idx] = line + " comment=" + rscQuote(strings.TrimSuffix(prefix, " "))
+		}
+	}
+
+	var ret strings.Builder
+	if cleanup {
+		ret.WriteString("# Remove the items of the previous conversion\n")
+		var find = "remove [find where comment~" + rscQuote("^"+regexp.QuoteMeta(strings.TrimSuffix(prefix, " "))) + "]\n"
+		for idx := len(menus) - 1; idx >= 0; idx-- {
+			if !untaggedMenus[menus[idx]] {
+				writeSection(&ret, menus[idx], find)
+				continue
+			}
+			var commands strings.Builder
+			for _, name := range names[menus[idx]] {
+				commands.WriteString("remove [find " + name + "]\n")
+			}
+			writeSection(&ret, menus[idx], commands.String())
+		}
+	}
+	ret.WriteString(strings.Join(lines, "\n"))
+	return ret.String()
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+const ActionDefaultDeny = "default deny"
+
+type TraceStep struct {
+	Policy  Policy
+	Matched bool
+	Reason  string
+}
+
+type TraceResult struct {
+	Steps  []TraceStep
+	Policy *Policy
+	Action string
+}
+
+// tracePacket evaluates a packet going from a zone to another against the policies, in the ScreenOS evaluation order:
+// zone pair policies first, then global policies. The first matching policy decides; if none matches, the packet is
+// denied.
+func tracePacket(policies []Policy, objects Objects, services Services, from string, to string, pkt Packet) TraceResult {
+	var ret = TraceResult{Action: ActionDefaultDeny}
+
+	var candidates = zonePairPolicies(policies, from, to)
+	for idx := range candidates {
+		p := candidates[idx]
+		reason := policyMismatch(p, objects, services, pkt)
+		ret.Steps = append(ret.Steps, TraceStep{Policy: p, Matched: reason == "", Reason: reason})
+		if reason == "" {
+			ret.Policy = &candidates[idx]
+			ret.Action = p.Action
+			break
+		}
+	}
+	return ret
+}
+
+// zonePairPolicies returns the policies applied to traffic between two zones, in evaluation order.
+func zonePairPolicies(policies []Policy, from string, to string) []Policy {
+	var ret []Policy
+	for _, global := range []bool{false, true} {
+		for _, p := range policies {
+			if global && p.From == ZoneGlobal && p.To == ZoneGlobal && (from != ZoneGlobal || to != ZoneGlobal) {
+				ret = append(ret, p)
+			} else if !global && p.From == from && p.To == to {
+				ret = append(ret, p)
+			}
+		}
+	}
+	return ret
+}
+
+// policyMismatch returns why the policy doesn't match the packet, or an empty string if it matches.
+func policyMismatch(p Policy, objects Objects, services Services, pkt Packet) string {
+	if p.Disabled {
+		return "disabled"
+	}
+
+	var point = pkt.Box()
+	if !rangesContain(addressRanges(objects, p.From, p.Sources), point.Src) {
+		return "source not matched"
+	}
+	if !rangesContain(addressRanges(objects, p.To, p.Destinations), point.Dst) {
+		return "destination not matched"
+	}
+
+	svc, unknown := serviceSpace(services, p.Services)
+	for _, b := range svc {
+		b.Src = point.Src
+		b.Dst = point.Dst
+		if b.Contains(point) {
+			return ""
+		}
+	}
+	if len(unknown) > 0 {
+		return "service not matched (undefined services: " + strings.Join(unknown, ", ") + ")"
+	}
+	return "service not matched"
+}
+
+func rangesContain(ranges []Range, r Range) bool {
+	for _, candidate := range ranges {
+		if candidate.Lo <= r.Lo && r.Hi <= candidate.Hi {
+			return true
+		}
+	}
+	return false
+}
+
+func (t TraceResult) String() string {
+	var ret strings.Builder
+	for _, step := range t.Steps {
+		ret.WriteString(fmt.Sprint("policy ", step.Policy.ID))
+		if step.Policy.From == ZoneGlobal {
+			ret.WriteString(" (global)")
+		}
+		if step.Matched {
+			ret.WriteString(" matched\n")
+		} else {
+			ret.WriteString(" skipped: " + step.Reason + "\n")
+		}
+	}
+
+	if t.Policy == nil {
+		ret.WriteString("result: " + ActionDefaultDeny + " (no policy matched)\n")
+		return ret.String()
+	}
+
+	ret.WriteString(fmt.Sprint("result: ", t.Action, " by policy ", t.Policy.ID))
+	if t.Policy.Name != "" {
+		ret.WriteString(" \"" + t.Policy.Name + "\"")
+	}
+	if t.Policy.VPN != "" {
+		ret.WriteString(" through vpn " + t.Policy.VPN)
+	}
+	ret.WriteString("\n")
+
+	for _, dst := range t.Policy.Destinations {
+		if strings.HasPrefix(dst, "MIP(") || strings.HasPrefix(dst, "VIP(") {
+			ret.WriteString("nat: destination " + dst + "\n")
+		}
+	}
+	switch t.Policy.NAT {
+	case NatSrc:
+		ret.WriteString("nat: source")
+		if t.Policy.NATAddress != "" {
+			ret.WriteString(" to " + t.Policy.NATAddress)
+		} else {
+			ret.WriteString(" to the egress interface address")
+		}
+		ret.WriteString("\n")
+	case NatDst:
+		ret.WriteString("nat: destination to " + t.Policy.NATAddress)
+		if t.Policy.NATPort > 0 {
+			ret.WriteString(fmt.Sprint(" port ", t.Policy.NATPort))
+		}
+		ret.WriteString("\n")
+	}
+	return ret.String()
+}
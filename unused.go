@@ -0,0 +1,114 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// findUnused reports address objects, groups and custom services which are not referenced by any policy, directly
+// or through groups, as well as empty groups and groups with undefined members. Objects referenced only by disabled
+// policies are reported as such.
+func findUnused(cfg Config) []string {
+	const (
+		unused = iota
+		usedByDisabled
+		used
+	)
+
+	var objectUse = make(map[string]int)
+	var mark func(zone string, name string, use int)
+	mark = func(zone string, name string, use int) {
+		key := zone + "/" + name
+		if objectUse[key] >= use {
+			return
+		}
+		objectUse[key] = use
+
+		if obj, ok := cfg.Objects[zone][name]; ok {
+			for _, member := range obj.GroupMembers {
+				mark(zone, member, use)
+			}
+		}
+	}
+
+	var serviceUse = make(map[string]int)
+	for _, p := range cfg.Policies {
+		var use = used
+		if p.Disabled {
+			use = usedByDisabled
+		}
+		for _, name := range p.Sources {
+			mark(p.From, name, use)
+		}
+		for _, name := range p.Destinations {
+			mark(p.To, name, use)
+		}
+		for _, name := range p.Services {
+			if serviceUse[name] < use {
+				serviceUse[name] = use
+			}
+		}
+	}
+	for _, v := range cfg.VPN.VPNs {
+		for _, proxy := range v.ProxyIDs {
+			serviceUse[proxy.Service] = used
+		}
+	}
+
+	var ret []string
+	for _, zone := range cfg.Objects.Zones() {
+		for _, name := range cfg.Objects.Names(zone) {
+			obj := cfg.Objects[zone][name]
+
+			var kind = "object"
+			if obj.Group {
+				kind = "group"
+			}
+			switch objectUse[zone+"/"+name] {
+			case unused:
+				ret = append(ret, "unused "+kind+" "+zone+"/"+name+obj.Provenance.suffix())
+			case usedByDisabled:
+				ret = append(ret, kind+" "+zone+"/"+name+" used only by disabled policies"+obj.Provenance.suffix())
+			}
+
+			if obj.Group && len(obj.GroupMembers) == 0 {
+				ret = append(ret, "empty group "+zone+"/"+name+obj.Provenance.suffix())
+			}
+			for _, member := range obj.GroupMembers {
+				if _, ok := cfg.Objects[zone][member]; !ok {
+					ret = append(ret, "group "+zone+"/"+name+": undefined member "+member+obj.Provenance.suffix())
+				}
+			}
+		}
+	}
+
+	var defaults = defaultServices()
+	var names = make([]string, 0, len(cfg.Services))
+	for name := range cfg.Services {
+		if _, ok := defaults[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		provenance := serviceProvenance(cfg.Services[name]).suffix()
+		switch serviceUse[name] {
+		case unused:
+			ret = append(ret, "unused service "+name+provenance)
+		case usedByDisabled:
+			ret = append(ret, "service "+name+" used only by disabled policies"+provenance)
+		}
+	}
+
+	var undefined []string
+	for name := range serviceUse {
+		if _, ok := cfg.Services[name]; !ok && !strings.EqualFold(name, "any") {
+			undefined = append(undefined, name)
+		}
+	}
+	sort.Strings(undefined)
+	for _, name := range undefined {
+		ret = append(ret, "undefined service "+name)
+	}
+	return ret
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFindUnused(t *testing.T) {
+	const config = `
+set address "Trust" "h1" 10.0.0.1 255.255.255.255
+set address "Trust" "h2" 10.0.0.2 255.255.255.255
+set address "Trust" "h3" 10.0.0.3 255.255.255.255
+set address "DMZ" "s1" 10.1.0.1 255.255.255.255
+set group address "Trust" "Inner"
+set group address "Trust" "Inner" add "h2"
+set group address "Trust" "Outer"
+set group address "Trust" "Outer" add "Inner"
+set group address "Trust" "Outer" add "ghost"
+set group address "Trust" "Empty"
+set service "APP" protocol tcp src-port 0-65535 dst-port 8001-8001
+set service "OLD" protocol tcp src-port 0-65535 dst-port 8002-8002
+set policy id 1 from "Trust" to "DMZ"  "Outer" "s1" "HTTP" permit
+set policy id 2 from "Trust" to "DMZ"  "h3" "s1" "APP" permit
+set policy id 2 disable
+`
+	// Inner and h2 are used through Outer, s1 by both an enabled and a disabled policy
+	var want = []string{
+		"unused group Trust/Empty (test.cfg:10)",
+		"empty group Trust/Empty (test.cfg:10)",
+		"group Trust/Outer: undefined member ghost (test.cfg:7-9)",
+		"unused object Trust/h1 (test.cfg:1)",
+		"object Trust/h3 used only by disabled policies (test.cfg:3)",
+		"service APP used only by disabled policies (test.cfg:11)",
+		"unused service OLD (test.cfg:12)",
+	}
+	cfg := parse(strings.NewReader(strings.TrimPrefix(config, "\n")), "test.cfg")
+	if got := findUnused(cfg); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+)
+
+// Verdict is the final action for a set of packets, and the policy or rule which decided it.
+type Verdict struct {
+	Box    Box
+	Action string
+	Source string
+}
+
+// Difference is a set of packets between two zones with different verdicts in the ScreenOS policies and in the
+// RouterOS filter rules.
+type Difference struct {
+	From     string
+	To       string
+	ScreenOS Verdict
+	RouterOS Verdict
+}
+
+func (d Difference) String() string {
+	var inter, _ = d.ScreenOS.Box.Intersect(d.RouterOS.Box)
+	return fmt.Sprintf("%s -> %s: %s: ScreenOS %s (%s), RouterOS %s (%s), e.g. %s", d.From, d.To, inter.String(),
+		d.ScreenOS.Action, d.ScreenOS.Source, d.RouterOS.Action, d.RouterOS.Source, inter.Sample().String())
+}
+
+// screenosVerdicts returns the verdicts of the policies for the traffic between two zones, using the same action
+// names of RouterOS.
+func screenosVerdicts(policies []Policy, objects Objects, services Services, from string, to string) []Verdict {
+	var ret []Verdict
+	var rest = Space{FullBox}
+	for _, p := range zonePairPolicies(policies, from, to) {
+		if rest.IsEmpty() {
+			break
+		}
+
+		var matched Space
+		matched, rest = rest.Split(policySpace(p, objects, services))
+		if matched.IsEmpty() {
+			continue
+		}
+
+		var action = VerdictDrop
+		switch {
+		case p.Permits():
+			action = VerdictAccept
+		case p.Action == ActionReject:
+			action = VerdictReject
+		}
+		for _, b := range matched {
+			ret = append(ret, Verdict{Box: b, Action: action, Source: fmt.Sprint("policy ", p.ID, p.Provenance.suffix())})
+		}
+	}
+	for _, b := range rest {
+		ret = append(ret, Verdict{Box: b, Action: VerdictDrop, Source: ActionDefaultDeny})
+	}
+	return ret
+}
+
+// verifyConversion compares the ScreenOS policies and the RouterOS forward chain over the full IPv4 header space of
+// each zone pair, or of the pairs from or to the given zone. Zone pairs with the Global zone are covered by the global
+// policies of every pair.
+func verifyConversion(policies []Policy, objects Objects, services Services, firewall MikrotikFirewall, zones []string, zone string) ([]Difference, error) {
+	var ret []Difference
+	for _, from := range zones {
+		for _, to := range zones {
+			if from == ZoneGlobal || to == ZoneGlobal || (zone != "" && from != zone && to != zone) {
+				continue
+			}
+
+			mtk, err := firewall.Evaluate(from, to)
+			if err != nil {
+				return nil, err
+			}
+			for _, s := range screenosVerdicts(policies, objects, services, from, to) {
+				for _, m := range mtk {
+					if s.Action == m.Action {
+						continue
+					}
+					if _, ok := s.Box.Intersect(m.Box); ok {
+						ret = append(ret, Difference{From: from, To: to, ScreenOS: s, RouterOS: m})
+					}
+				}
+			}
+		}
+	}
+	return ret, nil
+}
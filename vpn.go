@@ -0,0 +1,174 @@
+package main
+
+import (
+	"net"
+	"regexp"
+	"sort"
+)
+
+const (
+	IKEModeMain       = "Main"
+	IKEModeAggressive = "Aggr"
+	AuthPreshare      = "preshare"
+)
+
+// Proposal is either an IKE phase 1 or an IPsec phase 2 proposal. DHGroup is 0 for phase 2 proposals without PFS.
+type Proposal struct {
+	Name       string
+	Auth       string
+	DHGroup    int
+	Protocol   string
+	Encryption string
+	Hash       string
+	Lifetime   int
+}
+
+type IKEGateway struct {
+	Name              string
+	Address           string
+	Dynamic           bool
+	Mode              string
+	LocalID           string
+	OutgoingInterface string
+	Preshared         bool
+	Proposals         []string
+	SecLevel          string
+	Unsupported       []string
+}
+
+type ProxyID struct {
+	Local   *net.IPNet
+	Remote  *net.IPNet
+	Service string
+}
+
+type VPN struct {
+	Name          string
+	Gateway       string
+	Replay        bool
+	Proposals     []string
+	SecLevel      string
+	BindInterface string
+	ProxyIDs      []ProxyID
+	Unsupported   []string
+}
+
+type VPNConfig struct {
+	P1Proposals map[string]Proposal
+	P2Proposals map[string]Proposal
+	Gateways    map[string]*IKEGateway
+	VPNs        map[string]*VPN
+}
+
+func newVPNConfig() VPNConfig {
+	return VPNConfig{
+		P1Proposals: make(map[string]Proposal),
+		P2Proposals: make(map[string]Proposal),
+		Gateways:    make(map[string]*IKEGateway),
+		VPNs:        make(map[string]*VPN),
+	}
+}
+
+// Gateway returns the IKE gateway with the given name, creating it if it doesn't exist yet.
+func (v VPNConfig) Gateway(name string) *IKEGateway {
+	if _, ok := v.Gateways[name]; !ok {
+		v.Gateways[name] = &IKEGateway{Name: name}
+	}
+	return v.Gateways[name]
+}
+
+// VPN returns the VPN with the given name, creating it if it doesn't exist yet.
+func (v VPNConfig) VPN(name string) *VPN {
+	if _, ok := v.VPNs[name]; !ok {
+		v.VPNs[name] = &VPN{Name: name}
+	}
+	return v.VPNs[name]
+}
+
+func (v VPNConfig) GatewayNames() []string {
+	var ret = make([]string, 0, len(v.Gateways))
+	for name := range v.Gateways {
+		ret = append(ret, name)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+func (v VPNConfig) VPNNames() []string {
+	var ret = make([]string, 0, len(v.VPNs))
+	for name := range v.VPNs {
+		ret = append(ret, name)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// secLevelProposals lists the predefined proposals behind each ScreenOS security level, for phase 1 and phase 2.
+var secLevelProposals = map[string][2][]string{
+	"standard":   {{"pre-g2-aes128-sha", "pre-g2-3des-sha"}, {"g2-esp-3des-sha", "g2-esp-aes128-sha"}},
+	"compatible": {{"pre-g2-3des-sha", "pre-g2-3des-md5", "pre-g2-des-sha", "pre-g2-des-md5"}, {"nopfs-esp-3des-sha", "nopfs-esp-3des-md5", "nopfs-esp-des-sha", "nopfs-esp-des-md5"}},
+	"basic":      {{"pre-g1-des-sha", "pre-g1-des-md5"}, {"nopfs-esp-des-sha", "nopfs-esp-des-md5"}},
+}
+
+var predefinedProposalRx = regexp.MustCompile("^((pre|rsa|dsa)-)?(g([0-9]+)|nopfs)-((esp|ah)-)?(des|3des|aes128|aes192|aes256|null)?-?(sha|md5|sha-256)$")
+
+// Phase1Proposals returns the phase 1 proposals used by the gateway, resolving security levels and predefined names.
+// Names which can't be resolved are returned as the second value.
+func (v VPNConfig) Phase1Proposals(gw *IKEGateway) ([]Proposal, []string) {
+	var names = gw.Proposals
+	if gw.SecLevel != "" {
+		names = secLevelProposals[gw.SecLevel][0]
+	}
+	return resolveProposals(names, v.P1Proposals, 28800)
+}
+
+// Phase2Proposals is like Phase1Proposals, for VPN phase 2 proposals.
+func (v VPNConfig) Phase2Proposals(vpn *VPN) ([]Proposal, []string) {
+	var names = vpn.Proposals
+	if vpn.SecLevel != "" {
+		names = secLevelProposals[vpn.SecLevel][1]
+	}
+	return resolveProposals(names, v.P2Proposals, 3600)
+}
+
+func resolveProposals(names []string, custom map[string]Proposal, lifetime int) ([]Proposal, []string) {
+	var ret []Proposal
+	var unknown []string
+	for _, name := range names {
+		if p, ok := custom[name]; ok {
+			ret = append(ret, p)
+			continue
+		}
+
+		parts := predefinedProposalRx.FindAllStringSubmatch(name, -1)
+		if len(parts) == 0 {
+			unknown = append(unknown, name)
+			continue
+		}
+
+		p := Proposal{
+			Name:       name,
+			Protocol:   parts[0][6],
+			Encryption: parts[0][7],
+			Hash:       parts[0][8],
+			Lifetime:   lifetime,
+		}
+		switch parts[0][2] {
+		case "pre":
+			p.Auth = AuthPreshare
+		case "rsa", "dsa":
+			p.Auth = parts[0][2] + "-sig"
+		}
+		switch parts[0][8] {
+		case "sha":
+			p.Hash = "sha-1"
+		case "sha-256":
+			p.Hash = "sha2-256"
+		}
+		if parts[0][4] != "" {
+			p.DHGroup = mustInt(parts[0][4])
+		}
+		ret = append(ret, p)
+	}
+	return ret, unknown
+}
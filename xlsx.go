@@ -0,0 +1,112 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// XLSXSheet is a worksheet of an XLSX workbook. Integer cells are written as numbers, the others as inline strings.
+type XLSXSheet struct {
+	Name string
+	Rows [][]string
+}
+
+const xlsxContentTypes = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+<Default Extension="xml" ContentType="application/xml"/>
+<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>
+%s</Types>
+`
+
+const xlsxRels = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>
+</Relationships>
+`
+
+// writeXLSX writes a minimal Office Open XML workbook with the given sheets.
+func writeXLSX(w io.Writer, sheets []XLSXSheet) error {
+	var overrides, workbook, workbookRels strings.Builder
+	workbook.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n" +
+		`<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+		`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` + "\n<sheets>\n")
+	workbookRels.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n" +
+		`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` + "\n")
+	for idx, sheet := range sheets {
+		n := idx + 1
+		overrides.WriteString(fmt.Sprintf(`<Override PartName="/xl/worksheets/sheet%d.xml" `+
+			`ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`+"\n", n))
+		workbook.WriteString(fmt.Sprintf(`<sheet name="%s" sheetId="%d" r:id="rId%d"/>`+"\n", xmlEscape(sheet.Name), n, n))
+		workbookRels.WriteString(fmt.Sprintf(`<Relationship Id="rId%d" `+
+			`Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" `+
+			`Target="worksheets/sheet%d.xml"/>`+"\n", n, n))
+	}
+	workbook.WriteString("</sheets>\n</workbook>\n")
+	workbookRels.WriteString("</Relationships>\n")
+
+	type file struct {
+		name    string
+		content string
+	}
+	var files = []file{
+		{"[Content_Types].xml", fmt.Sprintf(xlsxContentTypes, overrides.String())},
+		{"_rels/.rels", xlsxRels},
+		{"xl/workbook.xml", workbook.String()},
+		{"xl/_rels/workbook.xml.rels", workbookRels.String()},
+	}
+	for idx, sheet := range sheets {
+		files = append(files, file{fmt.Sprintf("xl/worksheets/sheet%d.xml", idx+1), xlsxWorksheet(sheet.Rows)})
+	}
+
+	var archive = zip.NewWriter(w)
+	for _, f := range files {
+		fw, err := archive.Create(f.name)
+		if err != nil {
+			return err
+		}
+		if _, err = io.WriteString(fw, f.content); err != nil {
+			return err
+		}
+	}
+	return archive.Close()
+}
+
+func xlsxWorksheet(rows [][]string) string {
+	var ret strings.Builder
+	ret.WriteString(`<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n" +
+		`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` + "\n<sheetData>\n")
+	for idx, row := range rows {
+		ret.WriteString(fmt.Sprintf(`<row r="%d">`, idx+1))
+		for jdx, cell := range row {
+			ref := xlsxColumn(jdx) + strconv.Itoa(idx+1)
+			if _, err := strconv.Atoi(cell); err == nil && (cell == "0" || !strings.HasPrefix(cell, "0")) {
+				ret.WriteString(`<c r="` + ref + `"><v>` + cell + `</v></c>`)
+			} else {
+				ret.WriteString(`<c r="` + ref + `" t="inlineStr"><is><t>` + xmlEscape(cell) + `</t></is></c>`)
+			}
+		}
+		ret.WriteString("</row>\n")
+	}
+	ret.WriteString("</sheetData>\n</worksheet>\n")
+	return ret.String()
+}
+
+// xlsxColumn returns the name of a column by index: A to Z, then AA and so on.
+func xlsxColumn(idx int) string {
+	var ret = ""
+	for idx++; idx > 0; idx = (idx - 1) / 26 {
+		ret = string(rune('A'+(idx-1)%26)) + ret
+	}
+	return ret
+}
+
+func xmlEscape(s string) string {
+	var ret strings.Builder
+	_ = xml.EscapeText(&ret, []byte(s))
+	return ret.String()
+}